@@ -60,9 +60,10 @@ func (i *Identifier) String() string       { return i.Value }
 // LET statement
 type LetStatement struct {
 	// e.g. `let x = 5 + 5`
-	Token token.Token // the token.LET token (let)
-	Name  *Identifier // the name of the variable (x)
-	Value Expression  // the RHS (5 + 5)
+	Token token.Token   // the token.LET token (let)
+	Name  *Identifier   // the name of the variable (x)
+	Names []*Identifier // instead of Name, when destructuring an array: `let [a, b] = [1, 2]`
+	Value Expression    // the RHS (5 + 5)
 }
 
 func (ls *LetStatement) statementNode()       {}
@@ -70,7 +71,15 @@ func (ls *LetStatement) TokenLiteral() string { return ls.Token.Literal }
 func (ls *LetStatement) String() string {
 	var out bytes.Buffer
 	out.WriteString(ls.TokenLiteral() + " ")
-	out.WriteString(ls.Name.String())
+	if ls.Names != nil {
+		names := []string{}
+		for _, n := range ls.Names {
+			names = append(names, n.String())
+		}
+		out.WriteString("[" + strings.Join(names, ", ") + "]")
+	} else {
+		out.WriteString(ls.Name.String())
+	}
 	out.WriteString(" = ")
 	if ls.Value != nil {
 		out.WriteString(ls.Value.String())
@@ -122,6 +131,16 @@ func (il *IntegerLiteral) expressionNode()      {}
 func (il *IntegerLiteral) TokenLiteral() string { return il.Token.Literal }
 func (il *IntegerLiteral) String() string       { return il.Token.Literal }
 
+// FLOAT LITERAL (expression)
+type FloatLiteral struct {
+	Token token.Token
+	Value float64
+}
+
+func (fl *FloatLiteral) expressionNode()      {}
+func (fl *FloatLiteral) TokenLiteral() string { return fl.Token.Literal }
+func (fl *FloatLiteral) String() string       { return fl.Token.Literal }
+
 // BOOLEAN LITERAL (expression)
 type Boolean struct {
 	Token token.Token
@@ -142,6 +161,26 @@ func (sl *StringLiteral) expressionNode()      {}
 func (sl *StringLiteral) TokenLiteral() string { return sl.Token.Literal }
 func (sl *StringLiteral) String() string       { return sl.Token.Literal }
 
+// INTERPOLATED STRINGS, `"x is ${x + 1}"`
+type InterpolatedString struct {
+	Token token.Token  // the token.STRING token
+	Parts []Expression // a StringLiteral for each piece of text, any other exp for each ${...}
+}
+
+func (is *InterpolatedString) expressionNode()      {}
+func (is *InterpolatedString) TokenLiteral() string { return is.Token.Literal }
+func (is *InterpolatedString) String() string {
+	var out bytes.Buffer
+	for _, part := range is.Parts {
+		if text, ok := part.(*StringLiteral); ok {
+			out.WriteString(text.Value)
+		} else {
+			out.WriteString("${" + part.String() + "}")
+		}
+	}
+	return out.String()
+}
+
 // PREFIX EXPRESSION
 type PrefixExpression struct {
 	Token    token.Token
@@ -207,12 +246,45 @@ type WhileExpression struct {
 	Token     token.Token // the `while` token
 	Condition Expression
 	Body      *BlockStatement
+	Else      *BlockStatement // optional; runs if the body never did
 }
 
 func (we *WhileExpression) expressionNode()      {}
 func (we *WhileExpression) TokenLiteral() string { return we.Token.Literal }
 func (we *WhileExpression) String() string {
-	return fmt.Sprintf("while %s { %s }", we.Condition.String(), we.Body.String())
+	s := fmt.Sprintf("while %s { %s }", we.Condition.String(), we.Body.String())
+	if we.Else != nil {
+		s += fmt.Sprintf(" else { %s }", we.Else.String())
+	}
+	return s
+}
+
+// DO-WHILE is a WHILE checking its condition after the body
+type DoWhileExpression struct {
+	Token     token.Token // the `do` token
+	Body      *BlockStatement
+	Condition Expression
+}
+
+func (dw *DoWhileExpression) expressionNode()      {}
+func (dw *DoWhileExpression) TokenLiteral() string { return dw.Token.Literal }
+func (dw *DoWhileExpression) String() string {
+	return fmt.Sprintf("do { %s } while %s", dw.Body.String(), dw.Condition.String())
+}
+
+// TRY/CATCH: if Body evaluates to an error, Catch is evaluated instead,
+// with the error message bound to Ident
+type TryExpression struct {
+	Token token.Token // the `try` token
+	Body  *BlockStatement
+	Ident *Identifier
+	Catch *BlockStatement
+}
+
+func (te *TryExpression) expressionNode()      {}
+func (te *TryExpression) TokenLiteral() string { return te.Token.Literal }
+func (te *TryExpression) String() string {
+	return fmt.Sprintf("try { %s } catch (%s) { %s }", te.Body.String(), te.Ident.String(), te.Catch.String())
 }
 
 // FOR loops, Python style
@@ -220,14 +292,24 @@ type ForLoop struct {
 	Token    token.Token // the `for` token
 	Iterator *Identifier
 	Elements []Expression // for array literals (`for i in [1,2,3])
-	Ident    Expression   // identifier (`let array = ... ; for i in array`)
+	Ident    Expression   // any other exp (`let array = ... ; for i in array`, `for c in chars(s)`)
 	Body     *BlockStatement
 }
 
 func (fl *ForLoop) expressionNode()      {}
 func (fl *ForLoop) TokenLiteral() string { return fl.Token.Literal }
 func (fl *ForLoop) String() string {
-	return fmt.Sprintf("for %s in %s { %s }", fl.Iterator.String(), fl.Elements, fl.Body)
+	iterable := ""
+	if fl.Ident != nil {
+		iterable = fl.Ident.String()
+	} else {
+		elements := []string{}
+		for _, el := range fl.Elements {
+			elements = append(elements, el.String())
+		}
+		iterable = "[" + strings.Join(elements, ", ") + "]"
+	}
+	return fmt.Sprintf("for %s in %s { %s }", fl.Iterator.String(), iterable, fl.Body.String())
 }
 
 type BlockStatement struct {
@@ -248,9 +330,11 @@ func (bs *BlockStatement) String() string {
 
 // FUNCTION LITERALS
 type FunctionLiteral struct {
-	Token  token.Token   // the `fn` token
-	Params []*Identifier //
-	Body   *BlockStatement
+	Token    token.Token   // the `fn` token
+	Name     *Identifier   // optional, `fn fact(n) { ... }`: the function is bound to it in its own scope
+	Params   []*Identifier //
+	Defaults []Expression  // one per param, nil if the param has no default (`fn(x, y = 10)`)
+	Body     *BlockStatement
 }
 
 func (fl *FunctionLiteral) expressionNode()      {}
@@ -258,10 +342,17 @@ func (fl *FunctionLiteral) TokenLiteral() string { return fl.Token.Literal }
 func (fl *FunctionLiteral) String() string {
 	var out bytes.Buffer
 	params := []string{}
-	for _, p := range fl.Params {
-		params = append(params, p.String())
+	for i, p := range fl.Params {
+		if i < len(fl.Defaults) && fl.Defaults[i] != nil {
+			params = append(params, p.String()+" = "+fl.Defaults[i].String())
+		} else {
+			params = append(params, p.String())
+		}
 	}
 	out.WriteString(fl.TokenLiteral())
+	if fl.Name != nil {
+		out.WriteString(" " + fl.Name.String())
+	}
 	out.WriteString("(")
 	out.WriteString(strings.Join(params, ", "))
 	out.WriteString(") ")
@@ -274,6 +365,7 @@ type CallExpression struct {
 	Token     token.Token // the `(` token
 	Function  Expression  // Identifier or FunctionLiteral
 	Arguments []Expression
+	Method    bool // `x.len()`: Function is the builtin's name, x is the first of the Arguments
 }
 
 func (ce *CallExpression) expressionNode()      {}
@@ -284,6 +376,10 @@ func (ce *CallExpression) String() string {
 	for _, a := range ce.Arguments {
 		args = append(args, a.String())
 	}
+	if ce.Method {
+		out.WriteString(args[0] + ".")
+		args = args[1:]
+	}
 	out.WriteString(ce.Function.String())
 	out.WriteString("(")
 	out.WriteString(strings.Join(args, ", "))
@@ -300,7 +396,13 @@ type MapFunction struct {
 
 func (m *MapFunction) expressionNode()      {}
 func (m *MapFunction) TokenLiteral() string { return m.Token.Literal }
-func (m *MapFunction) String() string       { return "map!" }
+func (m *MapFunction) String() string {
+	elements := []string{}
+	for _, el := range m.Elements {
+		elements = append(elements, el.String())
+	}
+	return fmt.Sprintf("map(%s, [%s])", m.Function.String(), strings.Join(elements, ", "))
+}
 
 // ARRAYS
 type ArrayLiteral struct {
@@ -1,6 +1,8 @@
 package ast
 
 import (
+	"fmt"
+	"github.com/stretchr/testify/assert"
 	"monkey/token"
 	"testing"
 )
@@ -25,3 +27,109 @@ func TestString(t *testing.T) {
 		t.Errorf("program.String() wrong. got=%q", program.String())
 	}
 }
+
+func TestDump(t *testing.T) {
+	integer := func(v int64) *IntegerLiteral {
+		return &IntegerLiteral{Token: token.Token{Type: token.INT, Literal: fmt.Sprint(v)}, Value: v}
+	}
+	// 1 + 2 * 3
+	program := &Program{
+		Statements: []Statement{
+			&ExpressionStatement{
+				Token: token.Token{Type: token.INT, Literal: "1"},
+				Expression: &InfixExpression{
+					Token:    token.Token{Type: token.PLUS, Literal: "+"},
+					Left:     integer(1),
+					Operator: "+",
+					Right: &InfixExpression{
+						Token:    token.Token{Type: token.ASTERISK, Literal: "*"},
+						Left:     integer(2),
+						Operator: "*",
+						Right:    integer(3),
+					},
+				},
+			},
+		},
+	}
+
+	expected := `*ast.Program
+  Statements[0]: *ast.ExpressionStatement
+    Expression: *ast.InfixExpression +
+      Left: *ast.IntegerLiteral 1
+      Right: *ast.InfixExpression *
+        Left: *ast.IntegerLiteral 2
+        Right: *ast.IntegerLiteral 3
+`
+	assert.Equal(t, expected, Dump(program))
+}
+
+func TestDumpSkipsMissingNodes(t *testing.T) {
+	x := &Identifier{Token: token.Token{Type: token.IDENT, Literal: "x"}, Value: "x"}
+	// if (x) { x }, without an else
+	exp := &IfExpression{
+		Token:       token.Token{Type: token.IF, Literal: "if"},
+		Condition:   x,
+		Consequence: &BlockStatement{Token: token.Token{Type: token.LBRACE, Literal: "{"}, Statements: []Statement{&ExpressionStatement{Expression: x}}},
+	}
+
+	expected := `*ast.IfExpression
+  Condition: *ast.Identifier x
+  Consequence: *ast.BlockStatement
+    Statements[0]: *ast.ExpressionStatement
+      Expression: *ast.Identifier x
+`
+	assert.Equal(t, expected, Dump(exp))
+}
+
+func TestForLoopString(t *testing.T) {
+	ident := func(name string) *Identifier {
+		return &Identifier{Token: token.Token{Type: token.IDENT, Literal: name}, Value: name}
+	}
+	body := &BlockStatement{Statements: []Statement{&ExpressionStatement{Expression: ident("i")}}}
+
+	// for i in [1, 2] { i }
+	literal := &ForLoop{
+		Token:    token.Token{Type: token.FOR, Literal: "for"},
+		Iterator: ident("i"),
+		Elements: []Expression{
+			&IntegerLiteral{Token: token.Token{Type: token.INT, Literal: "1"}, Value: 1},
+			&IntegerLiteral{Token: token.Token{Type: token.INT, Literal: "2"}, Value: 2},
+		},
+		Body: body,
+	}
+	assert.Equal(t, "for i in [1, 2] { i }", literal.String())
+
+	// for i in xs { i }
+	identifier := &ForLoop{
+		Token:    token.Token{Type: token.FOR, Literal: "for"},
+		Iterator: ident("i"),
+		Ident:    ident("xs"),
+		Body:     body,
+	}
+	assert.Equal(t, "for i in xs { i }", identifier.String())
+}
+
+func TestMapFunctionString(t *testing.T) {
+	x := &Identifier{Token: token.Token{Type: token.IDENT, Literal: "x"}, Value: "x"}
+	integer := func(v int64) *IntegerLiteral {
+		return &IntegerLiteral{Token: token.Token{Type: token.INT, Literal: fmt.Sprint(v)}, Value: v}
+	}
+	// map(fn(x){x*2}, [1,2,3])
+	m := &MapFunction{
+		Token: token.Token{Type: token.MAP, Literal: "map"},
+		Function: &FunctionLiteral{
+			Token:  token.Token{Type: token.FUNCTION, Literal: "fn"},
+			Params: []*Identifier{x},
+			Body: &BlockStatement{Statements: []Statement{
+				&ExpressionStatement{Expression: &InfixExpression{Left: x, Operator: "*", Right: integer(2)}},
+			}},
+		},
+		Elements: []Expression{integer(1), integer(2), integer(3)},
+	}
+	assert.Equal(t, "map(fn(x) (x * 2), [1, 2, 3])", m.String())
+
+	// with an identifier
+	m.Function = &Identifier{Token: token.Token{Type: token.IDENT, Literal: "double"}, Value: "double"}
+	m.Elements = nil
+	assert.Equal(t, "map(double, [])", m.String())
+}
@@ -0,0 +1,103 @@
+package ast
+
+import (
+	"bytes"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+var nodeType = reflect.TypeOf((*Node)(nil)).Elem()
+
+// Dump renders the tree under node, one node per line with its Go type,
+// indented by depth; unlike String() it shows the structure, e.g. for 1 + 2:
+//
+//	*ast.Program
+//	  Statements[0]: *ast.ExpressionStatement
+//	    Expression: *ast.InfixExpression +
+//	      Left: *ast.IntegerLiteral 1
+//	      Right: *ast.IntegerLiteral 2
+//
+// Operators are shown next to their node, and so are the literals of leaves.
+func Dump(node Node) string {
+	var out bytes.Buffer
+	dump(&out, node, "", 0)
+	return out.String()
+}
+
+type child struct {
+	label string
+	node  Node
+}
+
+func dump(out *bytes.Buffer, node Node, label string, depth int) {
+	out.WriteString(strings.Repeat("  ", depth))
+	if label != "" {
+		out.WriteString(label + ": ")
+	}
+	fmt.Fprintf(out, "%T", node)
+
+	children, operator := inspectNode(node)
+	if operator != "" {
+		out.WriteString(" " + operator)
+	} else if len(children) == 0 {
+		out.WriteString(" " + node.TokenLiteral())
+	}
+	out.WriteString("\n")
+
+	for _, c := range children {
+		dump(out, c.node, c.label, depth+1)
+	}
+}
+
+// inspectNode returns the children of node (the fields holding nodes, in
+// the order they're declared) and its operator, if it has one
+func inspectNode(node Node) (children []child, operator string) {
+	v := reflect.ValueOf(node)
+	if v.Kind() != reflect.Ptr || v.Elem().Kind() != reflect.Struct {
+		return nil, ""
+	}
+	v = v.Elem()
+	for i := 0; i < v.NumField(); i++ {
+		name, field := v.Type().Field(i).Name, v.Field(i)
+		switch {
+		case name == "Operator" && field.Kind() == reflect.String:
+			operator = field.String()
+		case field.Kind() == reflect.Slice:
+			for j := 0; j < field.Len(); j++ {
+				if n, ok := asNode(field.Index(j)); ok {
+					children = append(children, child{fmt.Sprintf("%s[%d]", name, j), n})
+				}
+			}
+		case field.Kind() == reflect.Map: // hash literals: sorted, for a stable output
+			pairs := [][2]child{}
+			for _, k := range field.MapKeys() {
+				key, okKey := asNode(k)
+				value, okValue := asNode(field.MapIndex(k))
+				if okKey && okValue {
+					pairs = append(pairs, [2]child{{"Key", key}, {"Value", value}})
+				}
+			}
+			sort.Slice(pairs, func(a, b int) bool {
+				return pairs[a][0].node.String() < pairs[b][0].node.String()
+			})
+			for _, pair := range pairs {
+				children = append(children, pair[0], pair[1])
+			}
+		default:
+			if n, ok := asNode(field); ok {
+				children = append(children, child{name, n})
+			}
+		}
+	}
+	return children, operator
+}
+
+// asNode returns the node in v, if it holds a (non nil) one
+func asNode(v reflect.Value) (Node, bool) {
+	if !v.Type().Implements(nodeType) || v.IsNil() {
+		return nil, false
+	}
+	return v.Interface().(Node), true
+}
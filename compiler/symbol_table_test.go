@@ -0,0 +1,24 @@
+package compiler
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestDefineResolve(t *testing.T) {
+	global := NewSymbolTable()
+	assert.Equal(t, Symbol{Name: "a", Scope: GlobalScope, Index: 0}, global.Define("a"))
+	assert.Equal(t, Symbol{Name: "b", Scope: GlobalScope, Index: 1}, global.Define("b"))
+
+	a, ok := global.Resolve("a")
+	assert.True(t, ok)
+	assert.Equal(t, 0, a.Index)
+
+	_, ok = global.Resolve("c")
+	assert.False(t, ok)
+
+	// defining a name again gives it a new slot
+	assert.Equal(t, Symbol{Name: "a", Scope: GlobalScope, Index: 2}, global.Define("a"))
+	a, _ = global.Resolve("a")
+	assert.Equal(t, 2, a.Index)
+}
@@ -1,10 +1,33 @@
 package evaluator
 
 import (
+	"bufio"
 	"fmt"
+	"io"
+	"math"
 	"monkey/object"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
 )
 
+// Stdin and Stdout are used by the builtins doing I/O, and Now by `now()`;
+// swap them to embed the interpreter somewhere else (or to test it)
+var (
+	Stdin            = bufio.NewReader(os.Stdin)
+	Stdout io.Writer = os.Stdout
+	Now              = time.Now
+)
+
+// the builtins that can also be called as methods, `x.len()`
+var methods = map[string]bool{
+	"len":   true,
+	"push":  true,
+	"first": true,
+}
+
 var builtins = map[string]*object.Builtin{
 	"len": {
 		Fn: func(args ...object.Object) object.Object {
@@ -13,9 +36,11 @@ var builtins = map[string]*object.Builtin{
 			}
 			switch arg := args[0].(type) {
 			case *object.String:
-				return &object.Integer{Value: int64(len(arg.Value))}
+				return newInteger(int64(len(arg.Value)))
 			case *object.Array:
-				return &object.Integer{Value: int64(len(arg.Elements))}
+				return newInteger(int64(len(arg.Elements)))
+			case *object.HashMap:
+				return newInteger(int64(len(arg.Pairs)))
 			default:
 				return newError("argument to `len` not supported, got %s", args[0].Type())
 			}
@@ -37,12 +62,936 @@ var builtins = map[string]*object.Builtin{
 			}
 		},
 	},
+	"first": {
+		Fn: func(args ...object.Object) object.Object {
+			if len(args) != 1 {
+				return newError("wrong number of arguments. got=%d, want=1", len(args))
+			}
+			switch arg := args[0].(type) {
+			case *object.Array:
+				if len(arg.Elements) > 0 {
+					return arg.Elements[0]
+				}
+				return NULL
+			default:
+				return newError("argument to `first` not supported, got %s", args[0].Type())
+			}
+		},
+	},
+	"push": {
+		Fn: func(args ...object.Object) object.Object {
+			if len(args) != 2 {
+				return newError("wrong number of arguments. got=%d, want=2", len(args))
+			}
+			arr, ok := args[0].(*object.Array)
+			if !ok {
+				return newError("argument to `push` must be ARRAY, got %s", args[0].Type())
+			}
+			// arrays are immutable: return a new one
+			elements := make([]object.Object, len(arr.Elements), len(arr.Elements)+1)
+			copy(elements, arr.Elements)
+			return &object.Array{Elements: append(elements, args[1])}
+		},
+	},
 	"puts": {
 		Fn: func(args ...object.Object) object.Object {
 			for _, arg := range args {
-				fmt.Println(arg.Inspect())
+				fmt.Fprintln(Stdout, object.ToString(arg))
 			}
 			return NULL
 		},
 	},
+	// like puts, but without newlines (or anything else) between arguments
+	"print": {
+		Fn: func(args ...object.Object) object.Object {
+			for _, arg := range args {
+				fmt.Fprint(Stdout, object.ToString(arg))
+			}
+			return NULL
+		},
+	},
+	// assert(cond) or assert(cond, message): a failed assertion is an error,
+	// so it stops the program
+	"assert": {
+		Fn: func(args ...object.Object) object.Object {
+			if len(args) != 1 && len(args) != 2 {
+				return newError("wrong number of arguments. got=%d, want=1 or 2", len(args))
+			}
+			if isTruthy(args[0]) {
+				return NULL
+			}
+			if len(args) == 2 {
+				return newError("assertion failed: %s", object.ToString(args[1]))
+			}
+			return newError("assertion failed")
+		},
+	},
+	// error(message) raises an error, which stops the program
+	// like the ones from the interpreter
+	"error": {
+		Fn: func(args ...object.Object) object.Object {
+			if len(args) != 1 {
+				return newError("wrong number of arguments. got=%d, want=1", len(args))
+			}
+			msg, ok := args[0].(*object.String)
+			if !ok {
+				return newError("argument to `error` must be STRING, got %s", args[0].Type())
+			}
+			return newError("%s", msg.Value)
+		},
+	},
+	// exit() and exit(code) stop the program; try/catch doesn't catch them
+	"exit": {
+		Fn: func(args ...object.Object) object.Object {
+			if len(args) > 1 {
+				return newError("wrong number of arguments. got=%d, want=0 or 1", len(args))
+			}
+			if len(args) == 0 {
+				return &object.Exit{Code: 0}
+			}
+			code, ok := args[0].(*object.Integer)
+			if !ok {
+				return newError("argument to `exit` must be INTEGER, got %s", args[0].Type())
+			}
+			return &object.Exit{Code: int(code.Value)}
+		},
+	},
+	"str": {
+		Fn: func(args ...object.Object) object.Object {
+			if len(args) != 1 {
+				return newError("wrong number of arguments. got=%d, want=1", len(args))
+			}
+			return &object.String{Value: object.ToString(args[0])}
+		},
+	},
+	// now() is the current Unix time, in milliseconds
+	"now": {
+		Fn: func(args ...object.Object) object.Object {
+			if len(args) != 0 {
+				return newError("wrong number of arguments. got=%d, want=0", len(args))
+			}
+			return newInteger(Now().UnixMilli())
+		},
+	},
+	"read_file": {
+		Fn: func(args ...object.Object) object.Object {
+			if len(args) != 1 {
+				return newError("wrong number of arguments. got=%d, want=1", len(args))
+			}
+			path, ok := args[0].(*object.String)
+			if !ok {
+				return newError("argument to `read_file` must be STRING, got %s", args[0].Type())
+			}
+			data, err := os.ReadFile(path.Value)
+			if err != nil {
+				return newError("cannot read file: %s", err)
+			}
+			return &object.String{Value: string(data)}
+		},
+	},
+	"div": {
+		Fn: func(args ...object.Object) object.Object {
+			if len(args) != 2 {
+				return newError("wrong number of arguments. got=%d, want=2", len(args))
+			}
+			a, ok1 := args[0].(*object.Integer)
+			b, ok2 := args[1].(*object.Integer)
+			if !ok1 || !ok2 {
+				return newError("arguments to `div` must be INTEGER, got %s and %s", args[0].Type(), args[1].Type())
+			}
+			return floorDivide(a.Value, b.Value)
+		},
+	},
+	"mod": {
+		Fn: func(args ...object.Object) object.Object {
+			if len(args) != 2 {
+				return newError("wrong number of arguments. got=%d, want=2", len(args))
+			}
+			a, ok1 := args[0].(*object.Integer)
+			b, ok2 := args[1].(*object.Integer)
+			if !ok1 || !ok2 {
+				return newError("arguments to `mod` must be INTEGER, got %s and %s", args[0].Type(), args[1].Type())
+			}
+			return floorModulo(a.Value, b.Value)
+		},
+	},
+	"input": {
+		Fn: func(args ...object.Object) object.Object {
+			if len(args) > 1 {
+				return newError("wrong number of arguments. got=%d, want=0 or 1", len(args))
+			}
+			if len(args) == 1 {
+				fmt.Fprint(Stdout, object.ToString(args[0])) // the prompt
+			}
+			line, err := Stdin.ReadString('\n')
+			if err == io.EOF && line == "" {
+				return NULL
+			}
+			if err != nil && err != io.EOF {
+				return newError("cannot read input: %s", err)
+			}
+			line = strings.TrimSuffix(line, "\n")
+			line = strings.TrimSuffix(line, "\r")
+			return &object.String{Value: line}
+		},
+	},
+	"contains": {
+		Fn: func(args ...object.Object) object.Object {
+			if len(args) != 2 {
+				return newError("wrong number of arguments. got=%d, want=2", len(args))
+			}
+			switch arg := args[0].(type) {
+			case *object.String: // substring
+				sub, ok := args[1].(*object.String)
+				if !ok {
+					return newError("second argument to `contains` must be STRING, got %s", args[1].Type())
+				}
+				return nativeBoolToBooleanObject(strings.Contains(arg.Value, sub.Value))
+			case *object.Array: // element
+				for _, el := range arg.Elements {
+					if objectsEqual(el, args[1]) {
+						return TRUE
+					}
+				}
+				return FALSE
+			case *object.HashMap: // key
+				key, ok := args[1].(object.Hashable)
+				if !ok {
+					return FALSE // can't be a key
+				}
+				_, found := arg.Pairs[key.HashKey()]
+				return nativeBoolToBooleanObject(found)
+			default:
+				return newError("argument to `contains` not supported, got %s", args[0].Type())
+			}
+		},
+	},
+	"startsWith": {
+		Fn: func(args ...object.Object) object.Object {
+			strs, err := stringArgs("startsWith", args, 2)
+			if err != nil {
+				return err
+			}
+			return nativeBoolToBooleanObject(strings.HasPrefix(strs[0], strs[1]))
+		},
+	},
+	"endsWith": {
+		Fn: func(args ...object.Object) object.Object {
+			strs, err := stringArgs("endsWith", args, 2)
+			if err != nil {
+				return err
+			}
+			return nativeBoolToBooleanObject(strings.HasSuffix(strs[0], strs[1]))
+		},
+	},
+	// replace(s, old, new) replaces every old in s; an empty old is an error
+	// (Go would insert new between every rune, which is rarely what you want)
+	"replace": {
+		Fn: func(args ...object.Object) object.Object {
+			strs, err := stringArgs("replace", args, 3)
+			if err != nil {
+				return err
+			}
+			if strs[1] == "" {
+				return newError("cannot `replace` an empty string")
+			}
+			return &object.String{Value: strings.ReplaceAll(strs[0], strs[1], strs[2])}
+		},
+	},
+	// like chars(), indexOf counts runes: indexOf("héllo", "l") is 2
+	"indexOf": {
+		Fn: func(args ...object.Object) object.Object {
+			if len(args) != 2 {
+				return newError("wrong number of arguments. got=%d, want=2", len(args))
+			}
+			switch arg := args[0].(type) {
+			case *object.String:
+				sub, ok := args[1].(*object.String)
+				if !ok {
+					return newError("second argument to `indexOf` must be STRING, got %s", args[1].Type())
+				}
+				i := strings.Index(arg.Value, sub.Value)
+				if i < 0 {
+					return newInteger(-1)
+				}
+				return newInteger(int64(utf8.RuneCountInString(arg.Value[:i])))
+			case *object.Array:
+				for i, el := range arg.Elements {
+					if objectsEqual(el, args[1]) {
+						return newInteger(int64(i))
+					}
+				}
+				return newInteger(-1)
+			default:
+				return newError("argument to `indexOf` not supported, got %s", args[0].Type())
+			}
+		},
+	},
+	// substr(s, start, end) is s from start (included) to end (excluded),
+	// counting runes; negative indices count from the end, like -1 for
+	// the last rune, and bounds out of range are clamped: it never fails
+	"substr": {
+		Fn: func(args ...object.Object) object.Object {
+			if len(args) != 3 {
+				return newError("wrong number of arguments. got=%d, want=3", len(args))
+			}
+			str, ok := args[0].(*object.String)
+			if !ok {
+				return newError("first argument to `substr` must be STRING, got %s", args[0].Type())
+			}
+			start, ok1 := args[1].(*object.Integer)
+			end, ok2 := args[2].(*object.Integer)
+			if !ok1 || !ok2 {
+				return newError("bounds of `substr` must be INTEGER, got %s and %s", args[1].Type(), args[2].Type())
+			}
+			runes := []rune(str.Value)
+			from, to := clampIndex(start.Value, len(runes)), clampIndex(end.Value, len(runes))
+			if from >= to {
+				return &object.String{Value: ""}
+			}
+			return &object.String{Value: string(runes[from:to])}
+		},
+	},
+	"abs": {
+		Fn: func(args ...object.Object) object.Object {
+			if len(args) != 1 {
+				return newError("wrong number of arguments. got=%d, want=1", len(args))
+			}
+			switch arg := args[0].(type) {
+			case *object.Integer:
+				if arg.Value < 0 {
+					return checkedInteger(subInt64(0, arg.Value))
+				}
+				return arg
+			case *object.Float:
+				return &object.Float{Value: math.Abs(arg.Value)}
+			default:
+				return newError("argument to `abs` must be a number, got %s", args[0].Type())
+			}
+		},
+	},
+	// pow of two integers is an integer, unless the exponent is negative
+	"pow": {
+		Fn: func(args ...object.Object) object.Object {
+			if len(args) != 2 {
+				return newError("wrong number of arguments. got=%d, want=2", len(args))
+			}
+			if !isNumber(args[0]) || !isNumber(args[1]) {
+				return newError("arguments to `pow` must be numbers, got %s and %s", args[0].Type(), args[1].Type())
+			}
+			base, ok1 := args[0].(*object.Integer)
+			exp, ok2 := args[1].(*object.Integer)
+			if ok1 && ok2 && exp.Value >= 0 {
+				return checkedInteger(powInt64(base.Value, exp.Value))
+			}
+			return &object.Float{Value: math.Pow(toFloat(args[0]), toFloat(args[1]))}
+		},
+	},
+	// sqrt of a perfect square is an integer: sqrt(16) is 4, sqrt(2) is 1.414...
+	"sqrt": {
+		Fn: func(args ...object.Object) object.Object {
+			if len(args) != 1 {
+				return newError("wrong number of arguments. got=%d, want=1", len(args))
+			}
+			if !isNumber(args[0]) {
+				return newError("argument to `sqrt` must be a number, got %s", args[0].Type())
+			}
+			x := toFloat(args[0])
+			if x < 0 {
+				return newError("cannot take the square root of a negative number")
+			}
+			root := math.Sqrt(x)
+			if i, ok := args[0].(*object.Integer); ok {
+				// the float root can be off by one for big integers
+				for r := int64(root) - 1; r <= int64(root)+1; r++ {
+					if r >= 0 && r*r == i.Value {
+						return newInteger(r)
+					}
+				}
+			}
+			return &object.Float{Value: root}
+		},
+	},
+	"floor": {
+		Fn: func(args ...object.Object) object.Object {
+			return rounding("floor", args, math.Floor)
+		},
+	},
+	"ceil": {
+		Fn: func(args ...object.Object) object.Object {
+			return rounding("ceil", args, math.Ceil)
+		},
+	},
+	"min": {
+		Fn: func(args ...object.Object) object.Object {
+			return minMax("min", args, func(a, b float64) bool { return a < b })
+		},
+	},
+	"max": {
+		Fn: func(args ...object.Object) object.Object {
+			return minMax("max", args, func(a, b float64) bool { return a > b })
+		},
+	},
+	"reverse": {
+		Fn: func(args ...object.Object) object.Object {
+			if len(args) != 1 {
+				return newError("wrong number of arguments. got=%d, want=1", len(args))
+			}
+			// always a new object, the argument is left untouched
+			switch arg := args[0].(type) {
+			case *object.String:
+				runes := []rune(arg.Value)
+				for i, j := 0, len(runes)-1; i < j; i, j = i+1, j-1 {
+					runes[i], runes[j] = runes[j], runes[i]
+				}
+				return &object.String{Value: string(runes)}
+			case *object.Array:
+				elements := make([]object.Object, len(arg.Elements))
+				for i, el := range arg.Elements {
+					elements[len(elements)-1-i] = el
+				}
+				return &object.Array{Elements: elements}
+			default:
+				return newError("argument to `reverse` not supported, got %s", args[0].Type())
+			}
+		},
+	},
+	// there's no char type: a character is a string with a single rune,
+	// so chars("héllo") has 5 elements (even if the string is 6 bytes long)
+	"chars": {
+		Fn: func(args ...object.Object) object.Object {
+			if len(args) != 1 {
+				return newError("wrong number of arguments. got=%d, want=1", len(args))
+			}
+			str, ok := args[0].(*object.String)
+			if !ok {
+				return newError("argument to `chars` must be STRING, got %s", args[0].Type())
+			}
+			elements := []object.Object{}
+			for _, r := range str.Value {
+				elements = append(elements, &object.String{Value: string(r)})
+			}
+			return &object.Array{Elements: elements}
+		},
+	},
+	// chr(65) is "A", and ord("A") is 65: code points, not bytes
+	"chr": {
+		Fn: func(args ...object.Object) object.Object {
+			if len(args) != 1 {
+				return newError("wrong number of arguments. got=%d, want=1", len(args))
+			}
+			i, ok := args[0].(*object.Integer)
+			if !ok {
+				return newError("argument to `chr` must be INTEGER, got %s", args[0].Type())
+			}
+			if i.Value < 0 || i.Value > utf8.MaxRune || !utf8.ValidRune(rune(i.Value)) {
+				return newError("argument to `chr` is not a valid code point: %d", i.Value)
+			}
+			return &object.String{Value: string(rune(i.Value))}
+		},
+	},
+	"ord": {
+		Fn: func(args ...object.Object) object.Object {
+			if len(args) != 1 {
+				return newError("wrong number of arguments. got=%d, want=1", len(args))
+			}
+			str, ok := args[0].(*object.String)
+			if !ok {
+				return newError("argument to `ord` must be STRING, got %s", args[0].Type())
+			}
+			if utf8.RuneCountInString(str.Value) != 1 {
+				return newError("argument to `ord` must be a single character, got %q", str.Value)
+			}
+			r, _ := utf8.DecodeRuneInString(str.Value)
+			return newInteger(int64(r))
+		},
+	},
+	"sum": {
+		Fn: func(args ...object.Object) object.Object {
+			if len(args) != 1 {
+				return newError("wrong number of arguments. got=%d, want=1", len(args))
+			}
+			array, ok := args[0].(*object.Array)
+			if !ok {
+				return newError("argument to `sum` must be ARRAY, got %s", args[0].Type())
+			}
+			// same rules as `+`: integers stay integers, unless there's a float
+			var result object.Object = newInteger(0)
+			for _, el := range array.Elements {
+				if !isNumber(el) {
+					return newError("elements of `sum` must be numbers, got %s", el.Type())
+				}
+				result = evalInfixExpression("+", result, el)
+				if isError(result) {
+					return result
+				}
+			}
+			return result
+		},
+	},
+	// enumerate([a, b]) is [[0, a], [1, b]]: to loop with an index,
+	// for p in enumerate(xs) { let [i, x] = p; ... }
+	"enumerate": {
+		Fn: func(args ...object.Object) object.Object {
+			if len(args) != 1 {
+				return newError("wrong number of arguments. got=%d, want=1", len(args))
+			}
+			arr, ok := args[0].(*object.Array)
+			if !ok {
+				return newError("argument to `enumerate` must be ARRAY, got %s", args[0].Type())
+			}
+			pairs := make([]object.Object, len(arr.Elements))
+			for i, el := range arr.Elements {
+				pairs[i] = &object.Array{Elements: []object.Object{newInteger(int64(i)), el}}
+			}
+			return &object.Array{Elements: pairs}
+		},
+	},
+	// flatten([[1], [2, [3]]]) is [1, 2, 3]; flatten(arr, 1) only
+	// flattens one level, so the same array gives [1, 2, [3]]
+	"flatten": {
+		Fn: func(args ...object.Object) object.Object {
+			if len(args) != 1 && len(args) != 2 {
+				return newError("wrong number of arguments. got=%d, want=1 or 2", len(args))
+			}
+			arr, ok := args[0].(*object.Array)
+			if !ok {
+				return newError("argument to `flatten` must be ARRAY, got %s", args[0].Type())
+			}
+			depth := int64(-1) // all the way down
+			if len(args) == 2 {
+				d, ok := args[1].(*object.Integer)
+				if !ok {
+					return newError("depth of `flatten` must be INTEGER, got %s", args[1].Type())
+				}
+				if d.Value < 0 {
+					return newError("depth of `flatten` must not be negative, got %d", d.Value)
+				}
+				depth = d.Value
+			}
+			return &object.Array{Elements: flatten(arr.Elements, depth)}
+		},
+	},
+	// unique keeps the first of the elements equal (as in ==) to each other
+	"unique": {
+		Fn: func(args ...object.Object) object.Object {
+			if len(args) != 1 {
+				return newError("wrong number of arguments. got=%d, want=1", len(args))
+			}
+			arr, ok := args[0].(*object.Array)
+			if !ok {
+				return newError("argument to `unique` must be ARRAY, got %s", args[0].Type())
+			}
+			elements := []object.Object{}
+		outer:
+			for _, el := range arr.Elements {
+				for _, seen := range elements {
+					if objectsEqual(el, seen) {
+						continue outer
+					}
+				}
+				elements = append(elements, el)
+			}
+			return &object.Array{Elements: elements}
+		},
+	},
+	// take(arr, n) is the first n elements, drop(arr, n) all but those
+	"take": {
+		Fn: func(args ...object.Object) object.Object {
+			arr, n, err := arrayAndCount("take", args)
+			if err != nil {
+				return err
+			}
+			elements := make([]object.Object, n)
+			copy(elements, arr.Elements)
+			return &object.Array{Elements: elements}
+		},
+	},
+	"drop": {
+		Fn: func(args ...object.Object) object.Object {
+			arr, n, err := arrayAndCount("drop", args)
+			if err != nil {
+				return err
+			}
+			elements := make([]object.Object, len(arr.Elements)-n)
+			copy(elements, arr.Elements[n:])
+			return &object.Array{Elements: elements}
+		},
+	},
+	// count(["a", "b", "a"]) is {"a": 2, "b": 1}
+	"count": {
+		Fn: func(args ...object.Object) object.Object {
+			if len(args) != 1 {
+				return newError("wrong number of arguments. got=%d, want=1", len(args))
+			}
+			arr, ok := args[0].(*object.Array)
+			if !ok {
+				return newError("argument to `count` must be ARRAY, got %s", args[0].Type())
+			}
+			pairs := make(map[object.HashKey]object.HashPair)
+			for _, el := range arr.Elements {
+				key, ok := el.(object.Hashable)
+				if !ok {
+					return newError("unusable as hash key: %s", el.Type())
+				}
+				n := int64(0)
+				if pair, ok := pairs[key.HashKey()]; ok {
+					n = pair.Value.(*object.Integer).Value
+				}
+				pairs[key.HashKey()] = object.HashPair{Key: el, Value: newInteger(n + 1)}
+			}
+			return &object.HashMap{Pairs: pairs}
+		},
+	},
+	"clone": {
+		Fn: func(args ...object.Object) object.Object {
+			if len(args) != 1 {
+				return newError("wrong number of arguments. got=%d, want=1", len(args))
+			}
+			switch args[0].(type) {
+			case *object.Array, *object.HashMap:
+				return deepCopy(args[0])
+			default:
+				return newError("argument to `clone` must be ARRAY or HASHMAP, got %s", args[0].Type())
+			}
+		},
+	},
+	// hex(255) is "0xff"; negative numbers get their sign in front
+	// of the prefix, hex(-255) is "-0xff" (not a two's complement)
+	"hex":         radixFormat("hex", "0x", 16),
+	"oct":         radixFormat("oct", "0o", 8),
+	"bin":         radixFormat("bin", "0b", 2),
+	"is_string":   typePredicate(object.STRING_OBJ),
+	"is_number":   typePredicate(object.INTEGER_OBJ, object.FLOAT_OBJ),
+	"is_array":    typePredicate(object.ARRAY_OBJ),
+	"is_hash":     typePredicate(object.HASHMAP_OBJ),
+	"is_function": typePredicate(object.FUNCTION_OBJ, object.BUILTIN_OBJ),
+	"to_json": {
+		Fn: func(args ...object.Object) object.Object {
+			if len(args) != 1 {
+				return newError("wrong number of arguments. got=%d, want=1", len(args))
+			}
+			s, err := toJSON(args[0])
+			if err != nil {
+				return err
+			}
+			return &object.String{Value: s}
+		},
+	},
+	"parse_json": {
+		Fn: func(args ...object.Object) object.Object {
+			if len(args) != 1 {
+				return newError("wrong number of arguments. got=%d, want=1", len(args))
+			}
+			s, ok := args[0].(*object.String)
+			if !ok {
+				return newError("argument to `parse_json` must be STRING, got %s", args[0].Type())
+			}
+			return fromJSON(s.Value)
+		},
+	},
+	// format("x={}, y={}", 1, 2) => "x=1, y=2"; use {{ and }} for literal braces
+	"format": {
+		Fn: func(args ...object.Object) object.Object {
+			if len(args) == 0 {
+				return newError("wrong number of arguments. got=0, want at least 1")
+			}
+			f, ok := args[0].(*object.String)
+			if !ok {
+				return newError("first argument to `format` must be STRING, got %s", args[0].Type())
+			}
+			values := args[1:]
+
+			var out strings.Builder
+			placeholders := 0
+			for i := 0; i < len(f.Value); i++ {
+				switch {
+				case strings.HasPrefix(f.Value[i:], "{{"), strings.HasPrefix(f.Value[i:], "}}"):
+					out.WriteByte(f.Value[i])
+					i++ // skip the second brace
+				case strings.HasPrefix(f.Value[i:], "{}"):
+					if placeholders < len(values) {
+						out.WriteString(object.ToString(values[placeholders]))
+					}
+					placeholders++
+					i++ // skip the }
+				default:
+					out.WriteByte(f.Value[i])
+				}
+			}
+			if placeholders != len(values) {
+				return newError("wrong number of arguments for `format`: %d placeholders, got %d values", placeholders, len(values))
+			}
+			return &object.String{Value: out.String()}
+		},
+	},
+}
+
+// the builtins taking a function call back into the evaluator, so they can't
+// be in the `builtins` literal: that would be an initialization cycle
+func init() {
+	// map_values(fn(v) { v * 2 }, {"a": 1}) is {"a": 2}
+	builtins["map_values"] = &object.Builtin{
+		Fn: func(args ...object.Object) object.Object {
+			if len(args) != 2 {
+				return newError("wrong number of arguments. got=%d, want=2", len(args))
+			}
+			hash, ok := args[1].(*object.HashMap)
+			if !ok {
+				return newError("second argument to `map_values` must be HASHMAP, got %s", args[1].Type())
+			}
+			pairs := make(map[object.HashKey]object.HashPair, len(hash.Pairs))
+			for k, pair := range hash.Pairs {
+				value := applyFunction(args[0], []object.Object{pair.Value})
+				if isError(value) {
+					return value
+				}
+				pairs[k] = object.HashPair{Key: pair.Key, Value: value}
+			}
+			return &object.HashMap{Pairs: pairs}
+		},
+	}
+	// each(fn(x) { puts(x) }, [1, 2]) is like map, for the side effects only
+	builtins["each"] = &object.Builtin{
+		Fn: func(args ...object.Object) object.Object {
+			if len(args) != 2 {
+				return newError("wrong number of arguments. got=%d, want=2", len(args))
+			}
+			arr, ok := args[1].(*object.Array)
+			if !ok {
+				return newError("second argument to `each` must be ARRAY, got %s", args[1].Type())
+			}
+			for _, el := range arr.Elements {
+				if result := applyFunction(args[0], []object.Object{el}); isError(result) {
+					return result
+				}
+			}
+			return NULL
+		},
+	}
+	// group_by(fn(x) { mod(x, 2) }, [1, 2, 3]) is {1: [1, 3], 0: [2]}
+	builtins["group_by"] = &object.Builtin{
+		Fn: func(args ...object.Object) object.Object {
+			if len(args) != 2 {
+				return newError("wrong number of arguments. got=%d, want=2", len(args))
+			}
+			arr, ok := args[1].(*object.Array)
+			if !ok {
+				return newError("second argument to `group_by` must be ARRAY, got %s", args[1].Type())
+			}
+			pairs := make(map[object.HashKey]object.HashPair)
+			for _, el := range arr.Elements {
+				key := applyFunction(args[0], []object.Object{el})
+				if isError(key) {
+					return key
+				}
+				hashable, ok := key.(object.Hashable)
+				if !ok {
+					return newError("unusable as hash key: %s", key.Type())
+				}
+				group, ok := pairs[hashable.HashKey()]
+				if !ok {
+					group = object.HashPair{Key: key, Value: &object.Array{}}
+				}
+				// the arrays are brand new, so it's fine to grow them in place
+				bucket := group.Value.(*object.Array)
+				bucket.Elements = append(bucket.Elements, el)
+				pairs[hashable.HashKey()] = group
+			}
+			return &object.HashMap{Pairs: pairs}
+		},
+	}
+}
+
+// deepCopy copies arrays and hashmaps, recursively; anything else
+// is immutable, so it's shared with the original
+func deepCopy(obj object.Object) object.Object {
+	switch obj := obj.(type) {
+	case *object.Array:
+		elements := make([]object.Object, len(obj.Elements))
+		for i, el := range obj.Elements {
+			elements[i] = deepCopy(el)
+		}
+		return &object.Array{Elements: elements}
+	case *object.HashMap:
+		pairs := make(map[object.HashKey]object.HashPair, len(obj.Pairs))
+		for k, pair := range obj.Pairs {
+			pairs[k] = object.HashPair{Key: pair.Key, Value: deepCopy(pair.Value)}
+		}
+		return &object.HashMap{Pairs: pairs}
+	default:
+		return obj
+	}
+}
+
+// flatten appends the elements of the nested arrays, up to depth levels
+// down (or all of them, with a negative depth), to a new slice
+func flatten(elements []object.Object, depth int64) []object.Object {
+	out := []object.Object{}
+	for _, el := range elements {
+		if inner, ok := el.(*object.Array); ok && depth != 0 {
+			out = append(out, flatten(inner.Elements, depth-1)...)
+			continue
+		}
+		out = append(out, el)
+	}
+	return out
+}
+
+// typePredicate builds a builtin telling whether its argument
+// is of one of the given types
+func typePredicate(types ...object.ObjectType) *object.Builtin {
+	return &object.Builtin{
+		Fn: func(args ...object.Object) object.Object {
+			if len(args) != 1 {
+				return newError("wrong number of arguments. got=%d, want=1", len(args))
+			}
+			for _, t := range types {
+				if args[0].Type() == t {
+					return TRUE
+				}
+			}
+			return FALSE
+		},
+	}
+}
+
+// radixFormat builds a builtin writing an integer in base, after prefix
+func radixFormat(name, prefix string, base int) *object.Builtin {
+	return &object.Builtin{
+		Fn: func(args ...object.Object) object.Object {
+			if len(args) != 1 {
+				return newError("wrong number of arguments. got=%d, want=1", len(args))
+			}
+			i, ok := args[0].(*object.Integer)
+			if !ok {
+				return newError("argument to `%s` must be INTEGER, got %s", name, args[0].Type())
+			}
+			digits := strconv.FormatInt(i.Value, base)
+			if strings.HasPrefix(digits, "-") {
+				return &object.String{Value: "-" + prefix + digits[1:]}
+			}
+			return &object.String{Value: prefix + digits}
+		},
+	}
+}
+
+// clampIndex turns i into an index in [0, length]: negative ones
+// count from the end
+func clampIndex(i int64, length int) int {
+	if i < 0 {
+		i += int64(length)
+	}
+	if i < 0 {
+		return 0
+	}
+	if i > int64(length) {
+		return length
+	}
+	return int(i)
+}
+
+// arrayAndCount checks the arguments are an array and a non-negative
+// integer, and returns them; the integer is clamped to the array length
+func arrayAndCount(name string, args []object.Object) (*object.Array, int, *object.Error) {
+	if len(args) != 2 {
+		return nil, 0, newError("wrong number of arguments. got=%d, want=2", len(args))
+	}
+	arr, ok := args[0].(*object.Array)
+	if !ok {
+		return nil, 0, newError("first argument to `%s` must be ARRAY, got %s", name, args[0].Type())
+	}
+	n, ok := args[1].(*object.Integer)
+	if !ok {
+		return nil, 0, newError("second argument to `%s` must be INTEGER, got %s", name, args[1].Type())
+	}
+	if n.Value < 0 {
+		return nil, 0, newError("second argument to `%s` must not be negative, got %d", name, n.Value)
+	}
+	if n.Value > int64(len(arr.Elements)) {
+		return arr, len(arr.Elements), nil
+	}
+	return arr, int(n.Value), nil
+}
+
+// stringArgs checks there are exactly n arguments, all strings,
+// and returns their values
+func stringArgs(name string, args []object.Object, n int) ([]string, *object.Error) {
+	if len(args) != n {
+		return nil, newError("wrong number of arguments. got=%d, want=%d", len(args), n)
+	}
+	strs := make([]string, n)
+	for i, arg := range args {
+		s, ok := arg.(*object.String)
+		if !ok {
+			return nil, newError("arguments to `%s` must be STRING, got %s", name, arg.Type())
+		}
+		strs[i] = s.Value
+	}
+	return strs, nil
+}
+
+// rounding applies round (math.Floor, math.Ceil) to a number, and returns
+// the result as an integer
+func rounding(name string, args []object.Object, round func(float64) float64) object.Object {
+	if len(args) != 1 {
+		return newError("wrong number of arguments. got=%d, want=1", len(args))
+	}
+	switch arg := args[0].(type) {
+	case *object.Integer:
+		return arg
+	case *object.Float:
+		r := round(arg.Value)
+		// float64(MaxInt64) rounds up to 2^63, which doesn't fit
+		if math.IsNaN(r) || r < math.MinInt64 || r >= math.MaxInt64 {
+			return newError("integer overflow")
+		}
+		return newInteger(int64(r))
+	default:
+		return newError("argument to `%s` must be a number, got %s", name, args[0].Type())
+	}
+}
+
+// powInt64 is exponentiation by squaring; it returns false on overflow
+func powInt64(base, exp int64) (int64, bool) {
+	result := int64(1)
+	for exp > 0 {
+		var ok bool
+		if exp&1 == 1 {
+			if result, ok = mulInt64(result, base); !ok {
+				return 0, false
+			}
+		}
+		exp >>= 1
+		if exp > 0 {
+			if base, ok = mulInt64(base, base); !ok {
+				return 0, false
+			}
+		}
+	}
+	return result, true
+}
+
+// minMax works with either many numbers, min(3, 1, 2), or a single array
+// of numbers, min([3, 1, 2]); the result is the first number for which
+// better(number, every other number) holds
+func minMax(name string, args []object.Object, better func(a, b float64) bool) object.Object {
+	if len(args) == 1 {
+		if array, ok := args[0].(*object.Array); ok {
+			args = array.Elements
+		}
+	}
+	if len(args) == 0 {
+		return newError("`%s` needs at least one number", name)
+	}
+	var result object.Object
+	for _, arg := range args {
+		if !isNumber(arg) {
+			return newError("arguments to `%s` must be numbers, got %s", name, arg.Type())
+		}
+		if result == nil || better(toFloat(arg), toFloat(result)) {
+			result = arg
+		}
+	}
+	return result
 }
@@ -2,8 +2,11 @@ package evaluator
 
 import (
 	"fmt"
+	"math"
 	"monkey/ast"
 	"monkey/object"
+	"monkey/token"
+	"strings"
 )
 
 // Global objects
@@ -13,6 +16,40 @@ var (
 	FALSE = &object.Boolean{Value: false}
 )
 
+// like TRUE and FALSE, small integers are shared: loops and counters
+// don't allocate a new object for every result
+const (
+	minCachedInt = -128
+	maxCachedInt = 255
+)
+
+var smallInts = func() (cache [maxCachedInt - minCachedInt + 1]*object.Integer) {
+	for i := range cache {
+		cache[i] = &object.Integer{Value: int64(i + minCachedInt)}
+	}
+	return cache
+}()
+
+// newInteger returns the shared object for small values, a new one otherwise
+func newInteger(value int64) *object.Integer {
+	if minCachedInt <= value && value <= maxCachedInt {
+		return smallInts[value-minCachedInt]
+	}
+	return &object.Integer{Value: value}
+}
+
+// TrueDivision makes `/` between two integers return a float when the division
+// isn't exact (7 / 2 => 3.5). It's off by default, so 7 / 2 => 3 as in Go.
+// In both modes `~/` and the `div` builtin always do integer (floor) division.
+var TrueDivision = false
+
+// MaxCallDepth is how many nested function calls we allow before giving up
+// with an error, instead of letting a runaway recursion crash the Go stack
+var MaxCallDepth = 10000
+
+// current number of nested function calls
+var callDepth = 0
+
 /*
 	Example of a full program evaluation run printing debug info at the beginning of every Eval()
 		`let identity = fn(x) { x; }; identity(5);`
@@ -52,18 +89,25 @@ func Eval(node ast.Node, env *object.Environment) object.Object {
 		if isError(val) {
 			return val
 		}
+		if node.Names != nil {
+			return withLine(evalDestructuring(node.Names, val, env), node.Token)
+		}
 		env.Set(node.Name.Value, val) // bind the variable name to its val
 	// Expressions
 	case *ast.Identifier:
-		return evalIdentifier(node, env) // eval identifier (a variable)
+		return withLine(evalIdentifier(node, env), node.Token) // eval identifier (a variable)
 	case *ast.ReassignmentExpression:
-		return evalReassignment(node, env)
+		return withLine(evalReassignment(node, env), node.Token)
 	case *ast.ExpressionStatement:
 		return Eval(node.Expression, env)
 	case *ast.IntegerLiteral:
-		return &object.Integer{Value: node.Value}
+		return newInteger(node.Value)
+	case *ast.FloatLiteral:
+		return &object.Float{Value: node.Value}
 	case *ast.StringLiteral:
 		return &object.String{Value: node.Value}
+	case *ast.InterpolatedString:
+		return evalInterpolatedString(node, env)
 	case *ast.Boolean:
 		if node.Value {
 			return TRUE
@@ -75,25 +119,33 @@ func Eval(node ast.Node, env *object.Environment) object.Object {
 		if isError(right) {
 			return right
 		}
-		return evalPrefixExpression(node.Operator, right)
+		return withLine(evalPrefixExpression(node.Operator, right), node.Token)
 	case *ast.InfixExpression:
-		right := Eval(node.Right, env)
-		if isError(right) {
-			return right
+		if node.Operator == "&&" || node.Operator == "||" {
+			return evalLogicalExpression(node, env)
 		}
+		// left to right: an error in Left means Right isn't evaluated at all
 		left := Eval(node.Left, env)
 		if isError(left) {
 			return left
 		}
-		return evalInfixExpression(node.Operator, left, right)
+		right := Eval(node.Right, env)
+		if isError(right) {
+			return right
+		}
+		return withLine(evalInfixExpression(node.Operator, left, right), node.Token)
 	case *ast.BlockStatement:
 		return evalBlockStatement(node, env)
 	case *ast.IfExpression:
 		return evalIfExpression(node, env)
 	case *ast.WhileExpression:
 		return evalWhileExpression(node, env)
+	case *ast.DoWhileExpression:
+		return evalDoWhileExpression(node, env)
+	case *ast.TryExpression:
+		return evalTryExpression(node, env)
 	case *ast.ForLoop:
-		return evalForLoop(node, env)
+		return withLine(evalForLoop(node, env), node.Token)
 	case *ast.ReturnStatement:
 		val := Eval(node.ReturnValue, env)
 		if isError(val) {
@@ -101,11 +153,22 @@ func Eval(node ast.Node, env *object.Environment) object.Object {
 		}
 		return &object.ReturnValue{Value: val}
 	case *ast.FunctionLiteral:
-		return &object.Function{
+		fn := &object.Function{
 			Parameters: node.Params,
+			Defaults:   node.Defaults,
 			Body:       node.Body,
 			Env:        env}
+		// a named function sees itself, in a scope of its own
+		// between its body and env: the name doesn't leak out
+		if node.Name != nil {
+			fn.Env = object.NewEnclosedEnvironment(env)
+			fn.Env.Set(node.Name.Value, fn)
+		}
+		return fn
 	case *ast.CallExpression:
+		if node.Method {
+			return withLine(evalMethodCall(node, env), node.Token)
+		}
 		function := Eval(node.Function, env) // Function is an Identifier - myFunc() - or FunctionLiteral
 		if isError(function) {
 			return function
@@ -114,11 +177,11 @@ func Eval(node ast.Node, env *object.Environment) object.Object {
 		if len(args) == 1 && isError(args[0]) {
 			return args[0]
 		}
-		return applyFunction(function, args)
+		return withLine(applyFunction(function, args), node.Token)
 	case *ast.MapFunction:
 		function := Eval(node.Function, env)
 		args := evalExpressions(node.Elements, env)
-		return applyMapFunction(function, args)
+		return withLine(applyMapFunction(function, args), node.Token)
 	case *ast.ArrayLiteral:
 		elements := evalExpressions(node.Elements, env)
 		if len(elements) == 1 && isError(elements[0]) {
@@ -126,29 +189,24 @@ func Eval(node ast.Node, env *object.Environment) object.Object {
 		}
 		return &object.Array{Elements: elements}
 	case *ast.IndexExpression:
-		evIndex := Eval(node.Index, env)
-		if isError(evIndex) {
-			return evIndex
-		}
+		// left to right: in `a[0][1]`, Left is `a[0]`
 		evLeft := Eval(node.Left, env)
 		if isError(evLeft) {
 			return evLeft
 		}
-		return evalIndexExpression(evLeft, evIndex)
-	case *ast.HashLiteral:
-		hm := &object.HashMap{Pairs: map[string]object.Object{}}
-		for k, v := range node.Pairs {
-			key := Eval(k, env).(*object.String).Value
-			val := Eval(v, env)
-			hm.Pairs[key] = val
+		evIndex := Eval(node.Index, env)
+		if isError(evIndex) {
+			return evIndex
 		}
-		return hm
+		return withLine(evalIndexExpression(evLeft, evIndex), node.Token)
+	case *ast.HashLiteral:
+		return withLine(evalHashLiteral(node, env), node.Token)
 	}
 	return NULL
 }
 
 func evalProgram(program *ast.Program, env *object.Environment) object.Object {
-	var result object.Object
+	var result object.Object = NULL // a program with only comments or whitespace
 	for _, s := range program.Statements {
 		//fmt.Println("--- eval program statement: ", s.String())
 		result = Eval(s, env)
@@ -157,7 +215,7 @@ func evalProgram(program *ast.Program, env *object.Environment) object.Object {
 			return returnValue.Value
 		}
 		// we also immediately return errors
-		if result.Type() == object.ERROR_OBJ {
+		if isError(result) {
 			return result
 		}
 	}
@@ -168,12 +226,16 @@ func evalProgram(program *ast.Program, env *object.Environment) object.Object {
 // here every call to evalBlockSt returns the moment it finds a
 // Return OR an Error, so that the first one is always returned
 // since every call to evalBlockSt always returns
+//
+// Every block is a scope: `let` always binds in the current scope, so it
+// doesn't leak out of the block; `=` updates the binding wherever it is
 func evalBlockStatement(block *ast.BlockStatement, env *object.Environment) object.Object {
-	var result object.Object
+	var result object.Object = NULL // an empty block, as in `fn() {}`
+	blockEnv := object.NewEnclosedEnvironment(env)
 	for _, s := range block.Statements {
-		result = Eval(s, env)
+		result = Eval(s, blockEnv)
 		if result != nil &&
-			(result.Type() == object.RETURN_VALUE_OBJ || result.Type() == object.ERROR_OBJ) {
+			(result.Type() == object.RETURN_VALUE_OBJ || isError(result)) {
 			return result
 		}
 	}
@@ -209,58 +271,96 @@ func evalBlockStatement(block *ast.BlockStatement, env *object.Environment) obje
 //	return result
 //}
 
+// EvalPrefix and EvalInfix apply an operator to operands that are already
+// evaluated; the VM uses them, so that both backends agree on every operator
+func EvalPrefix(op string, right object.Object) object.Object {
+	return evalPrefixExpression(op, right)
+}
+
+func EvalInfix(op string, left, right object.Object) object.Object {
+	return evalInfixExpression(op, left, right)
+}
+
 func evalPrefixExpression(op string, right object.Object) object.Object {
 	switch op {
 	case "!":
 		return evalBangOperatorExp(right)
 	case "-":
 		return evalMinusOperatorExp(right)
+	case "~":
+		if i, ok := right.(*object.Integer); ok {
+			return newInteger(^i.Value)
+		}
+		return newError("unknown operator: ~%s", right.Type())
 	default:
 		return newError("unknown operator: %s%s", op, right.Type())
 	}
 }
 
+// `!x` is true exactly when `if (x)` wouldn't run: !0, !"" and ![] are false
 func evalBangOperatorExp(exp object.Object) object.Object {
-	//fmt.Printf("got exp %v of type %T\n", exp, exp)
-	switch exp {
-	case TRUE:
-		return FALSE
-	case FALSE:
-		return TRUE
-	case NULL:
-		return TRUE
-	default:
-		// anything else, like an int, is `true`, so !anything => false
-		return FALSE
-	}
+	return nativeBoolToBooleanObject(!isTruthy(exp))
 }
 
 func evalMinusOperatorExp(exp object.Object) object.Object {
+	if f, ok := exp.(*object.Float); ok {
+		return &object.Float{Value: -f.Value}
+	}
 	if exp.Type() != object.INTEGER_OBJ {
 		return newError("unknown operator: -%s", exp.Type())
 	}
 	value := exp.(*object.Integer).Value
-	return &object.Integer{Value: -value}
+	return checkedInteger(subInt64(0, value)) // there's no +9223372036854775808
+}
+
+// && and || short-circuit, and return one of the operands, not a boolean:
+// `a && b` is a if a is falsy, b otherwise; `a || b` is a if a is truthy,
+// b otherwise. So `let name = input() || "default"` works
+func evalLogicalExpression(node *ast.InfixExpression, env *object.Environment) object.Object {
+	left := Eval(node.Left, env)
+	if isError(left) {
+		return left
+	}
+	if isTruthy(left) == (node.Operator == "||") {
+		return left // no need to look at the right side
+	}
+	return Eval(node.Right, env)
 }
 
 func evalInfixExpression(op string, left, right object.Object) object.Object {
+	// any two objects can be compared: different types are never equal
+	// (so null only equals null), arrays and hashmaps are compared element
+	// by element
+	switch op {
+	case "==":
+		return nativeBoolToBooleanObject(objectsEqual(left, right))
+	case "!=":
+		return nativeBoolToBooleanObject(!objectsEqual(left, right))
+	}
+
+	// floats and integers can be mixed, the result is a float
+	if isNumber(left) && isNumber(right) &&
+		(left.Type() == object.FLOAT_OBJ || right.Type() == object.FLOAT_OBJ) {
+		return evalFloatInfixExpression(op, toFloat(left), toFloat(right))
+	}
+
+	if op == "*" && left.Type() == object.ARRAY_OBJ && right.Type() == object.INTEGER_OBJ {
+		return repeatArray(left.(*object.Array), right.(*object.Integer).Value)
+	}
+
 	// both sides of an infix exp must be of the same type
 	if left.Type() != right.Type() {
+		// `1 < x < 3` is `(1 < x) < 3`, which compares a boolean with a number
+		isComparison := op == "<" || op == ">" || op == "<=" || op == ">="
+		if isComparison && (left.Type() == object.BOOLEAN_OBJ || right.Type() == object.BOOLEAN_OBJ) {
+			return newError("cannot chain comparison operators; use && instead")
+		}
 		return newError("type mismatch: %s %s %s", left.Type(), op, right.Type())
 	}
 
-	// handle bools
-	if left.Type() == object.BOOLEAN_OBJ && right.Type() == object.BOOLEAN_OBJ {
-		l := left.(*object.Boolean)
-		r := right.(*object.Boolean)
-		switch op {
-		case "==":
-			return &object.Boolean{Value: l.Value == r.Value}
-		case "!=":
-			return &object.Boolean{Value: l.Value != r.Value}
-		default:
-			return newError("unknown operator: %s %s %s", left.Type(), op, right.Type())
-		}
+	// handle bools: only == and !=, already done
+	if left.Type() == object.BOOLEAN_OBJ {
+		return newError("unknown operator: %s %s %s", left.Type(), op, right.Type())
 	}
 
 	if left.Type() == object.INTEGER_OBJ {
@@ -268,21 +368,32 @@ func evalInfixExpression(op string, left, right object.Object) object.Object {
 		r := right.(*object.Integer)
 		switch op {
 		case "+":
-			return &object.Integer{Value: l.Value + r.Value}
+			return checkedInteger(addInt64(l.Value, r.Value))
 		case "-":
-			return &object.Integer{Value: l.Value - r.Value}
+			return checkedInteger(subInt64(l.Value, r.Value))
 		case "*":
-			return &object.Integer{Value: l.Value * r.Value}
+			return checkedInteger(mulInt64(l.Value, r.Value))
 		case "/":
-			return &object.Integer{Value: l.Value / r.Value}
+			if r.Value == 0 {
+				return newError("division by zero")
+			}
+			if l.Value == math.MinInt64 && r.Value == -1 {
+				return newError("integer overflow")
+			}
+			if TrueDivision && l.Value%r.Value != 0 {
+				return &object.Float{Value: float64(l.Value) / float64(r.Value)}
+			}
+			return newInteger(l.Value / r.Value)
+		case "~/":
+			return floorDivide(l.Value, r.Value)
 		case "<":
-			return &object.Boolean{Value: l.Value < r.Value}
+			return nativeBoolToBooleanObject(l.Value < r.Value)
 		case ">":
-			return &object.Boolean{Value: l.Value > r.Value}
-		case "==":
-			return &object.Boolean{Value: l.Value == r.Value}
-		case "!=":
-			return &object.Boolean{Value: l.Value != r.Value}
+			return nativeBoolToBooleanObject(l.Value > r.Value)
+		case "<=":
+			return nativeBoolToBooleanObject(l.Value <= r.Value)
+		case ">=":
+			return nativeBoolToBooleanObject(l.Value >= r.Value)
 		default:
 			return newError("unknown operator: %s %s %s", left.Type(), op, right.Type())
 
@@ -295,109 +406,265 @@ func evalInfixExpression(op string, left, right object.Object) object.Object {
 		switch op {
 		case "+":
 			return &object.String{Value: l.Value + r.Value}
+		// comparisons are lexicographic, byte by byte (so "B" < "a")
+		case "<":
+			return nativeBoolToBooleanObject(l.Value < r.Value)
+		case ">":
+			return nativeBoolToBooleanObject(l.Value > r.Value)
+		case "<=":
+			return nativeBoolToBooleanObject(l.Value <= r.Value)
+		case ">=":
+			return nativeBoolToBooleanObject(l.Value >= r.Value)
 		default:
 			return newError("unknown operator: %s %s %s", left.Type(), op, right.Type())
 		}
 	}
+	if left.Type() == object.ARRAY_OBJ {
+		l := left.(*object.Array)
+		r := right.(*object.Array)
+		switch op {
+		case "+": // always a new array, neither side is modified
+			elements := make([]object.Object, 0, len(l.Elements)+len(r.Elements))
+			elements = append(elements, l.Elements...)
+			elements = append(elements, r.Elements...)
+			return &object.Array{Elements: elements}
+		default:
+			return newError("unknown operator: %s %s %s", left.Type(), op, right.Type())
+		}
+	}
+
 	// everything else: type not supported
 	return newError("unsupported type: %s", left.Type())
 }
 
-// My own implementation, because the one in the book (see below)
-// breaks the tests.
+// repeatArray is `[0] * 3` => [0, 0, 0]. The elements aren't copied: the
+// new array holds the same objects n times, so in `[[]] * 2` both elements
+// are the very same array
+func repeatArray(arr *object.Array, n int64) object.Object {
+	if n < 0 {
+		return newError("cannot repeat an array a negative number of times: %d", n)
+	}
+	size, ok := mulInt64(int64(len(arr.Elements)), n)
+	if !ok || size > math.MaxInt32 {
+		return newError("array too large: %d * %d elements", len(arr.Elements), n)
+	}
+	elements := make([]object.Object, 0, size)
+	for i := int64(0); i < n; i++ {
+		elements = append(elements, arr.Elements...)
+	}
+	return &object.Array{Elements: elements}
+}
+
+// integers are int64: instead of silently wrapping around, fail with an error
+func checkedInteger(value int64, ok bool) object.Object {
+	if !ok {
+		return newError("integer overflow")
+	}
+	return newInteger(value)
+}
+
+// addInt64, subInt64 and mulInt64 return false if the result overflows
+func addInt64(a, b int64) (int64, bool) {
+	r := a + b
+	// overflow iff both operands have the same sign, and the result a different one
+	return r, (a >= 0) != (b >= 0) || (r >= 0) == (a >= 0)
+}
+
+func subInt64(a, b int64) (int64, bool) {
+	r := a - b
+	// overflow iff the operands have different signs, and the result isn't a's
+	return r, (a >= 0) == (b >= 0) || (r >= 0) == (a >= 0)
+}
+
+func mulInt64(a, b int64) (int64, bool) {
+	if a == 0 || b == 0 {
+		return 0, true
+	}
+	r := a * b
+	// -1 * MinInt64 is the one case where the division check below doesn't catch it
+	if (a == -1 && b == math.MinInt64) || (b == -1 && a == math.MinInt64) {
+		return r, false
+	}
+	return r, r/b == a
+}
+
+// floorDivide rounds toward negative infinity, unlike Go's `/`
+// which truncates toward zero: -7 ~/ 2 => -4, but -7 / 2 => -3
+func floorDivide(a, b int64) object.Object {
+	if b == 0 {
+		return newError("division by zero")
+	}
+	if a == math.MinInt64 && b == -1 { // like `/`
+		return newError("integer overflow")
+	}
+	q := a / b
+	if a%b != 0 && (a < 0) != (b < 0) {
+		q--
+	}
+	return newInteger(q)
+}
+
+// floorModulo is the remainder going with floorDivide: it has the sign
+// of b, and a == floorDivide(a, b) * b + floorModulo(a, b)
+func floorModulo(a, b int64) object.Object {
+	if b == 0 {
+		return newError("division by zero")
+	}
+	m := a % b
+	if m != 0 && (m < 0) != (b < 0) {
+		m += b
+	}
+	return newInteger(m)
+}
+
+func evalFloatInfixExpression(op string, l, r float64) object.Object {
+	switch op {
+	case "+":
+		return &object.Float{Value: l + r}
+	case "-":
+		return &object.Float{Value: l - r}
+	case "*":
+		return &object.Float{Value: l * r}
+	case "/":
+		return &object.Float{Value: l / r}
+	case "<":
+		return nativeBoolToBooleanObject(l < r)
+	case ">":
+		return nativeBoolToBooleanObject(l > r)
+	case "<=":
+		return nativeBoolToBooleanObject(l <= r)
+	case ">=":
+		return nativeBoolToBooleanObject(l >= r)
+	default:
+		return newError("unknown operator: %s %s %s", object.FLOAT_OBJ, op, object.FLOAT_OBJ)
+	}
+}
+
 func evalIfExpression(node *ast.IfExpression, env *object.Environment) object.Object {
 	cond := Eval(node.Condition, env)
 	if isError(cond) {
 		return cond
 	}
-	if cond.Type() == object.BOOLEAN_OBJ {
-		if cond.(*object.Boolean).Value { // if true
-			if node.Consequence != nil {
-				return Eval(node.Consequence, env)
-			}
-		} else { // bool is false
-			if node.Alternative != nil {
-				return Eval(node.Alternative, env)
-			}
-		}
-	}
-	if cond.Type() == object.INTEGER_OBJ {
-		if node.Consequence != nil {
-			return Eval(node.Consequence, env)
-		}
+	if isTruthy(cond) {
+		return Eval(node.Consequence, env)
+	} else if node.Alternative != nil {
+		return Eval(node.Alternative, env)
 	}
 	return NULL
 }
 
-// The following is the implementation suggested in the book,
-// but for some strange reason it doesn't work, so I kept my own.
-//func evalIfExpression(ie *ast.IfExpression) object.Object {
-//	condition := Eval(ie.Condition)
-//	fmt.Printf("got cond %v of type %T\n", condition, condition)
-//	if isTruthy(condition) {
-//		return Eval(ie.Consequence)
-//	} else if ie.Alternative != nil {
-//		return Eval(ie.Alternative)
-//	} else {
-//		return NULL
-//	}
-//}
+// isTruthy is the only place deciding what counts as true in a condition
+// (`if`, `while`...): null and false are falsy, anything else is truthy,
+// including 0, "" and [].
 //
-//// this always goes to default when evaluating an expression
-//// like (1 > 2), instead of matching FALSE, and I have no idea why
-//func isTruthy(obj object.Object) bool {
-//	switch obj {
-//	case NULL:
-//		fmt.Println("1")
-//		return false
-//	case TRUE:
-//		fmt.Println("2")
-//		return true
-//	case FALSE:
-//		fmt.Println("3")
-//		return false
-//	default:
-//		fmt.Println("4")
-//		return true
-//	}
-//}
+// The book compares obj against the TRUE/FALSE singletons; every boolean
+// is one of them now, but checking the value doesn't depend on it.
+func isTruthy(obj object.Object) bool {
+	switch obj := obj.(type) {
+	case *object.Null:
+		return false
+	case *object.Boolean:
+		return obj.Value
+	default:
+		return true
+	}
+}
 
 func evalWhileExpression(node *ast.WhileExpression, env *object.Environment) object.Object {
-	cond := Eval(node.Condition, env)
-	if isError(cond) {
-		return cond
-	}
-	if cond.Type() == object.BOOLEAN_OBJ {
-		for {
-			if !cond.(*object.Boolean).Value { // cond is false, exit
-				break
-			}
-			Eval(node.Body, env)
-			cond = Eval(node.Condition, env) // eval condition again!
+	iterations := 0
+	for {
+		cond := Eval(node.Condition, env) // eval condition at every iteration!
+		if isError(cond) {
+			return cond
+		}
+		if !isTruthy(cond) { // cond is false, exit
+			break
+		}
+		result := Eval(node.Body, env)
+		if result.Type() == object.RETURN_VALUE_OBJ || isError(result) {
+			return result
 		}
+		iterations++
+	}
+	// the else branch runs only if the condition was false right away
+	if iterations == 0 && node.Else != nil {
+		return Eval(node.Else, env)
 	}
 	return NULL
 }
 
+// a method is looked up among the builtins, never in the environment:
+// `x.len()` works even if `len` has been shadowed
+func evalMethodCall(node *ast.CallExpression, env *object.Environment) object.Object {
+	args := evalExpressions(node.Arguments, env) // receiver included
+	if len(args) == 1 && isError(args[0]) {
+		return args[0]
+	}
+	name := node.Function.(*ast.Identifier).Value
+	if !methods[name] {
+		return newError("undefined method %s for %s", name, args[0].Type())
+	}
+	return builtins[name].Fn(args...)
+}
+
+func evalDoWhileExpression(node *ast.DoWhileExpression, env *object.Environment) object.Object {
+	for {
+		result := Eval(node.Body, env) // at least once
+		if result.Type() == object.RETURN_VALUE_OBJ || isError(result) {
+			return result
+		}
+		cond := Eval(node.Condition, env)
+		if isError(cond) {
+			return cond
+		}
+		if !isTruthy(cond) {
+			return NULL
+		}
+	}
+}
+
+// errors can't be values (using one is raising it), so the catch block
+// gets the message of the error, as a string
+func evalTryExpression(node *ast.TryExpression, env *object.Environment) object.Object {
+	result := Eval(node.Body, env)
+	errObj, ok := result.(*object.Error)
+	if !ok {
+		return result
+	}
+	catchEnv := object.NewEnclosedEnvironment(env)
+	catchEnv.Set(node.Ident.Value, &object.String{Value: errObj.Message})
+	return Eval(node.Catch, catchEnv)
+}
+
 func evalForLoop(node *ast.ForLoop, env *object.Environment) object.Object {
-	// looping through an identifier
-	if node.Ident != nil {
+	var elements []object.Object
+	if node.Ident != nil { // looping through an identifier (or any other exp)
 		evald := Eval(node.Ident, env)
-		if array, ok := evald.(*object.Array); !ok {
+		if isError(evald) {
+			return evald
+		}
+		array, ok := evald.(*object.Array)
+		if !ok {
 			return newError("I can only loop through arrays; got %T instead", evald)
-		} else {
-			for _, a := range array.Elements {
-				env.Set(node.Iterator.Value, a) // set the iterator to the current evaluated element
-				Eval(node.Body, env)
-			}
-			return NULL // don't even try to evaluate array literal
+		}
+		elements = array.Elements
+	} else { // looping through array literal
+		elements = evalExpressions(node.Elements, env)
+		if len(elements) == 1 && isError(elements[0]) {
+			return elements[0]
 		}
 	}
 
-	// looping through array literal
-	for _, e := range node.Elements {
-		env.Set(node.Iterator.Value, Eval(e, env)) // set the iterator to the current evaluated element
-		Eval(node.Body, env)
+	for _, el := range elements {
+		// a new scope at every iteration, so that closures created in the body
+		// capture the value the iterator had in *that* iteration
+		iterEnv := object.NewEnclosedEnvironment(env)
+		iterEnv.Set(node.Iterator.Value, el) // set the iterator to the current element
+		// a return goes up to the function, like an error or an exit()
+		result := Eval(node.Body, iterEnv)
+		if result.Type() == object.RETURN_VALUE_OBJ || isError(result) {
+			return result
+		}
 	}
 	return NULL
 }
@@ -414,6 +681,34 @@ func evalIdentifier(node *ast.Identifier, env *object.Environment) object.Object
 	return newError("identifier not found: " + node.Value)
 }
 
+// `let [a, b] = [1, 2]` binds a = 1 and b = 2
+func evalDestructuring(names []*ast.Identifier, val object.Object, env *object.Environment) object.Object {
+	array, ok := val.(*object.Array)
+	if !ok {
+		return newError("cannot destructure %s, want ARRAY", val.Type())
+	}
+	if len(array.Elements) != len(names) {
+		return newError("cannot destructure: got %d values, want %d", len(array.Elements), len(names))
+	}
+	for i, name := range names {
+		env.Set(name.Value, array.Elements[i])
+	}
+	return NULL
+}
+
+// each ${...} is turned into a string the way puts prints it
+func evalInterpolatedString(node *ast.InterpolatedString, env *object.Environment) object.Object {
+	var out strings.Builder
+	for _, part := range node.Parts {
+		value := Eval(part, env)
+		if isError(value) {
+			return value
+		}
+		out.WriteString(object.ToString(value))
+	}
+	return &object.String{Value: out.String()}
+}
+
 func evalReassignment(node *ast.ReassignmentExpression, env *object.Environment) object.Object {
 	// make sure the left identifier is defined
 	if _, ok := env.Get(node.Left.Value); !ok {
@@ -421,9 +716,12 @@ func evalReassignment(node *ast.ReassignmentExpression, env *object.Environment)
 	}
 	// eval the right expression
 	value := Eval(node.Right, env)
+	if isError(value) {
+		return value
+	}
 
-	// update left identifier
-	env.Set(node.Left.Value, value)
+	// update left identifier, in the scope where it's defined
+	env.Assign(node.Left.Value, value)
 
 	return value
 }
@@ -444,6 +742,25 @@ func applyFunction(function object.Object, args []object.Object) object.Object {
 	switch fn := function.(type) {
 	// user-defined function
 	case *object.Function:
+		// params with a default value are always the last ones, and can be omitted
+		required := len(fn.Parameters)
+		for _, d := range fn.Defaults {
+			if d != nil {
+				required--
+			}
+		}
+		if len(args) < required || len(args) > len(fn.Parameters) {
+			if required == len(fn.Parameters) {
+				return newError("wrong number of arguments: got %d, want %d", len(args), required)
+			}
+			return newError("wrong number of arguments: got %d, want %d to %d", len(args), required, len(fn.Parameters))
+		}
+		if callDepth >= MaxCallDepth {
+			return newError("maximum recursion depth exceeded")
+		}
+		callDepth++
+		defer func() { callDepth-- }()
+
 		// we cannot just evaluate the function body, we need to bind the arguments it was called with to the env;
 		// we also don't want to override old bindings (defined in outer functions)
 
@@ -452,7 +769,16 @@ func applyFunction(function object.Object, args []object.Object) object.Object {
 
 		// and we bind the params to our new env
 		for i, param := range fn.Parameters {
-			extendedEnv.Set(param.Value, args[i]) // set IDENTIFIER = ARG, e.g. x = 5
+			if i < len(args) {
+				extendedEnv.Set(param.Value, args[i]) // set IDENTIFIER = ARG, e.g. x = 5
+				continue
+			}
+			// omitted arg: eval the default in the new env, so it can refer to the previous params
+			def := Eval(fn.Defaults[i], extendedEnv)
+			if isError(def) {
+				return def
+			}
+			extendedEnv.Set(param.Value, def)
 		}
 
 		evaluated := Eval(fn.Body, extendedEnv)
@@ -490,6 +816,10 @@ func unwrapReturnValue(obj object.Object) object.Object {
 
 func evalIndexExpression(obj, index object.Object) object.Object {
 	switch {
+	// a missing key (or index) earlier in a chain, as in `h["missing"]["name"]`:
+	// the whole chain is null
+	case obj == NULL:
+		return NULL
 	case obj.Type() == object.ARRAY_OBJ && index.Type() == object.INTEGER_OBJ:
 		arrayObj := obj.(*object.Array)
 		idx := index.(*object.Integer).Value
@@ -498,26 +828,128 @@ func evalIndexExpression(obj, index object.Object) object.Object {
 			return NULL
 		}
 		return arrayObj.Elements[idx]
-	case obj.Type() == object.HASHMAP_OBJ && index.Type() == object.STRING_OBJ:
-		hashObj := obj.(*object.HashMap)
-		key := index.(*object.String).Value
-		val, ok := hashObj.Pairs[key]
+	case obj.Type() == object.HASHMAP_OBJ:
+		key, ok := index.(object.Hashable)
+		if !ok {
+			return newError("unusable as hash key: %s", index.Type())
+		}
+		pair, ok := obj.(*object.HashMap).Pairs[key.HashKey()]
 		if !ok {
 			return NULL
 		}
-		return val
+		return pair.Value
 	default:
 		return newError("index operator not supported: %s", obj.Type())
 	}
 }
 
+func evalHashLiteral(node *ast.HashLiteral, env *object.Environment) object.Object {
+	pairs := map[object.HashKey]object.HashPair{}
+	for keyNode, valueNode := range node.Pairs {
+		key := Eval(keyNode, env)
+		if isError(key) {
+			return key
+		}
+		hashKey, ok := key.(object.Hashable)
+		if !ok {
+			return newError("unusable as hash key: %s", key.Type())
+		}
+		value := Eval(valueNode, env)
+		if isError(value) {
+			return value
+		}
+		pairs[hashKey.HashKey()] = object.HashPair{Key: key, Value: value}
+	}
+	return &object.HashMap{Pairs: pairs}
+}
+
 func newError(format string, a ...interface{}) *object.Error {
 	return &object.Error{Message: fmt.Sprintf(format, a...)}
 }
 
+// always return the global TRUE/FALSE objects
+func nativeBoolToBooleanObject(b bool) *object.Boolean {
+	if b {
+		return TRUE
+	}
+	return FALSE
+}
+
+// objectsEqual compares by value: scalars by their value (integers and
+// floats can be mixed), arrays and hashmaps element by element;
+// anything else (functions...) by identity
+func objectsEqual(a, b object.Object) bool {
+	if isNumber(a) && isNumber(b) && a.Type() != b.Type() {
+		return toFloat(a) == toFloat(b) // 1 == 1.0
+	}
+	if a.Type() != b.Type() {
+		return false
+	}
+	switch a := a.(type) {
+	case *object.Integer:
+		return a.Value == b.(*object.Integer).Value
+	case *object.Float:
+		return a.Value == b.(*object.Float).Value
+	case *object.Boolean:
+		return a.Value == b.(*object.Boolean).Value
+	case *object.String:
+		return a.Value == b.(*object.String).Value
+	case *object.Null:
+		return true
+	case *object.Array:
+		bElements := b.(*object.Array).Elements
+		if len(a.Elements) != len(bElements) {
+			return false
+		}
+		for i := range a.Elements {
+			if !objectsEqual(a.Elements[i], bElements[i]) {
+				return false
+			}
+		}
+		return true
+	case *object.HashMap:
+		bPairs := b.(*object.HashMap).Pairs
+		if len(a.Pairs) != len(bPairs) {
+			return false
+		}
+		for k, pair := range a.Pairs {
+			bPair, ok := bPairs[k]
+			if !ok || !objectsEqual(pair.Value, bPair.Value) {
+				return false
+			}
+		}
+		return true
+	default:
+		return a == b
+	}
+}
+
+func isNumber(obj object.Object) bool {
+	return obj.Type() == object.INTEGER_OBJ || obj.Type() == object.FLOAT_OBJ
+}
+
+// only call it on numbers (see isNumber)
+func toFloat(obj object.Object) float64 {
+	if i, ok := obj.(*object.Integer); ok {
+		return float64(i.Value)
+	}
+	return obj.(*object.Float).Value
+}
+
+// withLine sets the line of `tok` on obj, if it's an error without a line yet;
+// so an error always points to the innermost node that caused it
+func withLine(obj object.Object, tok token.Token) object.Object {
+	if errObj, ok := obj.(*object.Error); ok && errObj.Line == 0 {
+		errObj.Line = tok.Line
+	}
+	return obj
+}
+
+// isError is also true for an Exit: every check for errors has to stop
+// the evaluation for an exit() too
 func isError(obj object.Object) bool {
 	if obj != nil {
-		return obj.Type() == object.ERROR_OBJ
+		return obj.Type() == object.ERROR_OBJ || obj.Type() == object.EXIT_OBJ
 	}
 	return false
 }
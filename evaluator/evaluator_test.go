@@ -1,11 +1,19 @@
 package evaluator
 
 import (
+	"bufio"
+	"bytes"
+	"fmt"
 	"github.com/stretchr/testify/assert"
+	"math"
 	"monkey/lexer"
 	"monkey/object"
 	"monkey/parser"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
+	"time"
 )
 
 func TestEvalIntegerExpression(t *testing.T) {
@@ -31,6 +39,7 @@ func TestEvalIntegerExpression(t *testing.T) {
 		{"3 * 3 * 3 + 10", 37},
 		{"3 * (3 * 3) + 10", 37},
 		{"(5 + 10 * 2 + 15 / 3) * 2 + -10", 50},
+		{"1_000 + 1", 1001},
 	}
 
 	for _, tt := range tests {
@@ -39,6 +48,80 @@ func TestEvalIntegerExpression(t *testing.T) {
 	}
 }
 
+func TestIntegerOverflow(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"9223372036854775807 + 0", 9223372036854775807},
+		{"9223372036854775806 + 1", 9223372036854775807},
+		{"9223372036854775807 + 1", "integer overflow"},
+		{"-9223372036854775807 + -1", math.MinInt64},
+		{"-9223372036854775807 + -2", "integer overflow"},
+		{"9223372036854775807 + -9223372036854775807", 0},
+		{"-9223372036854775807 - 1", math.MinInt64},
+		{"-9223372036854775807 - 2", "integer overflow"},
+		{"9223372036854775807 - -1", "integer overflow"},
+		{"0 - 9223372036854775807", -9223372036854775807},
+		{"4611686018427387903 * 2", 9223372036854775806},
+		{"4611686018427387904 * 2", "integer overflow"},
+		{"-4611686018427387904 * 2", math.MinInt64},
+		{"-4611686018427387904 * -2", "integer overflow"},
+		{"(-9223372036854775807 - 1) * -1", "integer overflow"},
+		{"-1 * (-9223372036854775807 - 1)", "integer overflow"},
+		{"-(-9223372036854775807 - 1)", "integer overflow"},
+		{"(-9223372036854775807 - 1) * 1", math.MinInt64},
+		{"3037000500 * 3037000500", "integer overflow"},
+		{"0 * 9223372036854775807", 0},
+	}
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case string:
+			testErrorObject(t, evaluated, expected)
+		}
+	}
+}
+
+func TestEvalFloatExpression(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"1.5", 1.5},
+		{"-1.5", -1.5},
+		{"1.5e3", 1500.0},
+		{"2E-2", 0.02},
+		{"1e3", 1000.0},
+		{"1.5 + 1.5", 3.0},
+		{"1.5 + 1", 2.5},
+		{"1 + 1.5", 2.5},
+		{"2 * 1e-1", 0.2},
+		{"7 / 2.0", 3.5},
+		{"1.5 < 2", true},
+		{"1.5 >= 1.5", true},
+		{"1e3 == 1000", true},
+		{"abs(-7 / 2.0)", 3.5},
+		{"max(1, 2.5)", 2.5},
+		{"min([1.5, 0.5e1])", 1.5},
+		{"sum([1, 2.5])", 3.5},
+	}
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		switch expected := tt.expected.(type) {
+		case float64:
+			testFloatObject(t, evaluated, expected)
+		case bool:
+			testBooleanObject(t, evaluated, expected)
+		}
+	}
+
+	assert.Equal(t, "1500.0", testEval("1.5e3").Inspect())
+	assert.Equal(t, "0.02", testEval("2E-2").Inspect())
+}
+
 func TestStringLiteral(t *testing.T) {
 	input := `"Hello World!"`
 	evaluated := testEval(input)
@@ -47,6 +130,57 @@ func TestStringLiteral(t *testing.T) {
 	assert.Equal(t, "Hello World!", str.Value)
 }
 
+func TestStringInterpolation(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`let x = 41; "x is ${x + 1}"`, "x is 42"},
+		{`let name = "monkey"; "hello ${name}!"`, "hello monkey!"},
+		{`"${1}${2} ${true}"`, "12 true"},
+		{`"list: ${[1, 2]}, map: ${ {1: [2]} }"`, "list: [1, 2], map: {1: [2]}"},
+		{`let f = fn(x) { x * 2 }; "${f(2)} ${fn() { 3 }()}"`, "4 3"},
+		{`let s = "a"; "${s}${s + s}"`, "aaa"},
+		{`"not \${interpolated}"`, "not ${interpolated}"},
+		{`let h = {"k": "v"}; "${h["k"]}!"`, "v!"},
+		{`"a ${[1, "b"]}"`, `a [1, "b"]`},
+		{`let x = 1; "${"x is ${x}"}"`, "x is 1"},
+	}
+	for _, tt := range tests {
+		testStringObject(t, testEval(tt.input), tt.expected)
+	}
+
+	testErrorObject(t, testEval(`"${1 + true}"`), "type mismatch: INTEGER + BOOLEAN")
+	testErrorObject(t, testEval(`"${nope}"`), "identifier not found: nope")
+	// with the line where the ${ is
+	assert.Equal(t, "ERROR: line 2: identifier not found: nope", testEval("\"a\n${nope}\"").Inspect())
+}
+
+func TestStringComparison(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected bool
+	}{
+		{`"apple" < "banana"`, true},
+		{`"apple" > "banana"`, false},
+		{`"apple" <= "apple"`, true},
+		{`"apple" >= "apple"`, true},
+		{`"apple" < "apple"`, false},
+		{`"app" < "apple"`, true}, // a prefix comes first
+		{`"apple" > "app"`, true},
+		{`"" < "a"`, true},
+		{`"Banana" < "apple"`, true}, // case sensitive: uppercase comes first
+		{`"banana" < "Apple"`, false},
+		{`"apple" == "apple"`, true},
+		{`"apple" == "Apple"`, false},
+		{`"apple" != "banana"`, true},
+		{`"apple" != "apple"`, false},
+	}
+	for _, tt := range tests {
+		testBooleanObject(t, testEval(tt.input), tt.expected)
+	}
+}
+
 func TestStringConcatenation(t *testing.T) {
 	input := `"Hello" + " " + "World!"`
 	evaluated := testEval(input)
@@ -71,6 +205,11 @@ func TestEvalBooleanExpression(t *testing.T) {
 		{"1 != 1", false},
 		{"1 == 2", false},
 		{"1 != 2", true},
+		{"1 <= 2", true},
+		{"2 <= 2", true},
+		{"3 <= 2", false},
+		{"1 >= 2", false},
+		{"2 >= 2", true},
 		// operators with booleans
 		{"true == true", true},
 		{"false == false", true},
@@ -88,6 +227,106 @@ func TestEvalBooleanExpression(t *testing.T) {
 	}
 }
 
+func TestLogicalOperators(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{`true && true`, true},
+		{`true && false`, false},
+		{`false || true`, true},
+		{`false || false`, false},
+		// the operands themselves, not booleans
+		{`0 || 5`, 0}, // 0 is truthy
+		{`last([]) || 5`, 5},
+		{`false || "default"`, "default"},
+		{`"x" && "y"`, "y"},
+		{`false && "y"`, false},
+		{`last([]) && 1`, nil},
+		{`let input = last([]); let name = input || "default"; name`, "default"},
+		{`1 < 2 && 2 < 3`, true},
+		// short-circuit: the right side isn't evaluated
+		{`true || x`, true},
+		{`false && x`, false},
+		{`let n = 0; let inc = fn() { n = n + 1 }; false && inc(); true || inc(); n`, 0},
+		{`false || x`, "identifier not found: x"},
+		{`x && true`, "identifier not found: x"},
+	}
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		switch expected := tt.expected.(type) {
+		case bool:
+			testBooleanObject(t, evaluated, expected)
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case string:
+			if errObj, ok := evaluated.(*object.Error); ok {
+				assert.Equal(t, expected, errObj.Message)
+			} else {
+				testStringObject(t, evaluated, expected)
+			}
+		default:
+			testNullObject(t, evaluated)
+		}
+	}
+}
+
+func TestBitwiseNot(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected int64
+	}{
+		{"~5", -6},
+		{"~0", -1},
+		{"~-1", 0},
+		{"~~7", 7},
+		{"~5 + 1", -5},
+		{"~9223372036854775807", math.MinInt64},
+	}
+	for _, tt := range tests {
+		testIntegerObject(t, testEval(tt.input), tt.expected)
+	}
+	testErrorObject(t, testEval("~true"), "unknown operator: ~BOOLEAN")
+	testErrorObject(t, testEval("~1.5"), "unknown operator: ~FLOAT")
+}
+
+func TestSmallIntegerCache(t *testing.T) {
+	// small results are the shared objects...
+	assert.Same(t, testEval(`2`), testEval(`1 + 1`))
+	assert.Same(t, testEval(`-128`), testEval(`-64 * 2`))
+	assert.Same(t, testEval(`255`), testEval(`len("abc") * 85`))
+	// ...bigger ones aren't
+	assert.NotSame(t, testEval(`256`), testEval(`255 + 1`))
+	assert.NotSame(t, testEval(`-129`), testEval(`-128 - 1`))
+	testIntegerObject(t, testEval(`255 + 1`), 256)
+}
+
+// go test ./evaluator -bench ForLoop -benchmem
+func BenchmarkForLoopSum(b *testing.B) {
+	numbers := make([]string, 100)
+	for i := range numbers {
+		numbers[i] = fmt.Sprint(i % 10)
+	}
+	input := fmt.Sprintf("let acc = 0; for i in [%s] { acc = acc + i - i }; acc", strings.Join(numbers, ", "))
+	program := parser.New(lexer.New(input)).ParseProgram()
+
+	b.ReportAllocs()
+	for n := 0; n < b.N; n++ {
+		Eval(program, object.NewEnvironment())
+	}
+}
+
+func TestBooleanSingletons(t *testing.T) {
+	testBooleanObject(t, testEval(`(1 < 2) == true`), true)
+	// every boolean is TRUE or FALSE, whatever produced it
+	for _, input := range []string{`true`, `1 < 2`, `2 >= 2`, `1.5 > 1`, `"a" < "b"`, `1 == 1`, `[1] != [2]`, `!false`, `contains([1], 1)`, `is_string("")`} {
+		assert.Same(t, TRUE, testEval(input), input)
+	}
+	for _, input := range []string{`false`, `1 > 2`, `2 < 2`, `1.5 <= 1`, `"a" >= "b"`, `1 != 1`, `!true`, `!!last([])`, `startsWith("a", "b")`} {
+		assert.Same(t, FALSE, testEval(input), input)
+	}
+}
+
 func TestBangOperator(t *testing.T) {
 	tests := []struct {
 		input    string
@@ -99,6 +338,14 @@ func TestBangOperator(t *testing.T) {
 		{"!!true", true},
 		{"!!false", false},
 		{"!!5", true},
+		// same truthiness as `if`: only false and null are falsy
+		{"!0", false},
+		{"![]", false},
+		{`!""`, false},
+		{"!{}", false},
+		{"!last([])", true},
+		{"!(1 > 2)", true},
+		{"!!(1 < 2)", true},
 	}
 	for _, tt := range tests {
 		evaluated := testEval(tt.input)
@@ -121,6 +368,17 @@ func TestIfElseExpressions(t *testing.T) {
 		// this is an interesting one I added: the way we eval block statements
 		// means we only return the *last* statement of the bunch
 		{"if (true) { 10; 99; }", 99},
+		// everything but null and false is truthy
+		{"if (0) { 10 } else { 20 }", 10},
+		{`if ("x") { 10 } else { 20 }`, 10},
+		{`if ("") { 10 } else { 20 }`, 10},
+		{"if ([]) { 10 } else { 20 }", 10},
+		{"if ([1]) { 10 } else { 20 }", 10},
+		{"if (last([])) { 10 } else { 20 }", 20}, // null
+		{"if (last([])) { 10 }", nil},
+		// empty blocks are null
+		{"if (true) {}", nil},
+		{"if (false) { 10 } else {}", nil},
 	}
 	for _, tt := range tests {
 		evaluated := testEval(tt.input)
@@ -167,6 +425,12 @@ func TestReturnStatements(t *testing.T) {
 					return 1;
 					}`, 10,
 		},
+		// out of loops, up to the function
+		{"fn() { while (true) { return 5 } }()", 5},
+		{"fn() { do { return 6 } while (true) }()", 6},
+		{"fn() { for x in [1] { return 1 }; 99 }()", 1},
+		{"fn(xs) { for x in xs { if (x > 2) { return x } }; -1 }([1, 3, 5])", 3},
+		{"fn() { for x in [1, 2] { for y in [3, 4] { return x * y } }; 0 }()", 3},
 	}
 	for _, tt := range tests {
 		evaluated := testEval(tt.input)
@@ -218,6 +482,22 @@ func TestErrorHandling(t *testing.T) {
 			`"Hello" - "World"`,
 			"unknown operator: STRING - STRING",
 		},
+		{
+			"1 < 2 < 3",
+			"cannot chain comparison operators; use && instead",
+		},
+		{
+			"let x = 5; 10 >= x >= 1",
+			"cannot chain comparison operators; use && instead",
+		},
+		{
+			"1 < (2 > 3)",
+			"cannot chain comparison operators; use && instead",
+		},
+		{
+			`"a" < 1`,
+			"type mismatch: STRING < INTEGER",
+		},
 	}
 	for _, tt := range tests {
 		evaluated := testEval(tt.input)
@@ -234,6 +514,33 @@ func TestErrorHandling(t *testing.T) {
 	}
 }
 
+func TestErrorLineNumbers(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"5 + true", "ERROR: line 1: type mismatch: INTEGER + BOOLEAN"},
+		{"let x = 1;\nx + true", "ERROR: line 2: type mismatch: INTEGER + BOOLEAN"},
+		{"1;\n\n-true", "ERROR: line 3: unknown operator: -BOOLEAN"},
+		{"1;\nfoobar", "ERROR: line 2: identifier not found: foobar"},
+		{"let f = fn() {\n  1 + true\n};\nf()", "ERROR: line 2: type mismatch: INTEGER + BOOLEAN"}, // where it happens
+		{"let f = fn(x) { x };\n\nf(1, 2)", "ERROR: line 3: wrong number of arguments: got 2, want 1"},
+		{"\nlen(1)", "ERROR: line 2: argument to `len` not supported, got INTEGER"},
+		{"let x = 1;\nx[0]", "ERROR: line 2: index operator not supported: INTEGER"},
+		{"let h = {};\nh[[1]]", "ERROR: line 2: unusable as hash key: ARRAY"},
+		{"\n\n{[1]: 2}", "ERROR: line 3: unusable as hash key: ARRAY"},
+		{"\nfor x in true { x }", "ERROR: line 2: I can only loop through arrays; got *object.Boolean instead"},
+		{"1;\nlet [a, b] = 3", "ERROR: line 2: cannot destructure INTEGER, want ARRAY"},
+		{"1;\nnope = 2", "ERROR: line 2: identifier not found: nope"},
+		{"for x in [1] {\n  x + true\n}", "ERROR: line 2: type mismatch: INTEGER + BOOLEAN"}, // still the innermost
+	}
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		assert.IsType(t, &object.Error{}, evaluated)
+		assert.Equal(t, tt.expected, evaluated.Inspect())
+	}
+}
+
 func TestLetStatements(t *testing.T) {
 	tests := []struct {
 		input    string
@@ -249,6 +556,16 @@ func TestLetStatements(t *testing.T) {
 	}
 }
 
+func TestLetDestructuring(t *testing.T) {
+	testIntegerObject(t, testEval("let [a, b, c] = [1, 2, 3]; a * 100 + b * 10 + c"), 123)
+	testIntegerObject(t, testEval("let xs = [4, 5]; let [x, y] = xs; x + y"), 9)
+	testIntegerObject(t, testEval("let [x] = [[7]]; x[0]"), 7)
+
+	testErrorObject(t, testEval("let [a, b] = [1]"), "cannot destructure: got 1 values, want 2")
+	testErrorObject(t, testEval("let [a] = [1, 2]"), "cannot destructure: got 2 values, want 1")
+	testErrorObject(t, testEval("let [a] = 1"), "cannot destructure INTEGER, want ARRAY")
+}
+
 func TestFunctionObject(t *testing.T) {
 	input := "fn(x) { x + 2; };"
 	evaluated := testEval(input)
@@ -287,6 +604,124 @@ func TestFunctionApplication(t *testing.T) {
 	}
 }
 
+func TestStatementsWithoutSemicolons(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected int64
+	}{
+		{"let a = 1 let b = 2 a + b", 3},
+		{"let f = fn(x) { let y = x * 2 return y + 1 } f(1)", 3},
+		{"let x = 1 x = x + 1 x", 2},
+		{"return 1; 2", 1},
+	}
+	for _, tt := range tests {
+		testIntegerObject(t, testEval(tt.input), tt.expected)
+	}
+}
+
+func TestEmptyProgram(t *testing.T) {
+	for _, input := range []string{"", "   \n\t", "// just a comment"} {
+		testNullObject(t, testEval(input))
+	}
+}
+
+func TestEmptyBlocks(t *testing.T) {
+	tests := []string{
+		"fn() {}()",
+		"let f = fn(x) {}; f(1)",
+		"let f = fn() {}; let x = f(); x",
+		"while (false) {}",
+		"{}; if (true) {}",
+	}
+	for _, input := range tests {
+		testNullObject(t, testEval(input))
+	}
+	// and they don't break whatever comes after them
+	testIntegerObject(t, testEval("if (true) {}; 5"), 5)
+	testIntegerObject(t, testEval("let f = fn() {}; if (f()) { 1 } else { 2 }"), 2)
+}
+
+func TestNamedFunctions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		// no `let` needed to recurse
+		{"fn fact(n) { if (n < 2) { 1 } else { n * fact(n - 1) } }(5)", 120},
+		{"let f = fn fact(n) { if (n < 2) { 1 } else { n * fact(n - 1) } }; f(4)", 24},
+		{"let fib = fn f(n) { if (n < 2) { n } else { f(n - 1) + f(n - 2) } }; fib(10)", 55},
+		// the name is only visible inside the function
+		{"let f = fn inner() { 1 }; inner()", "identifier not found: inner"},
+		{"let inner = 2; let f = fn inner() { 1 }; inner", 2},
+		// and it hides an outer binding with the same name
+		{"let g = 10; let f = fn g() { g }; f() == f", true},
+	}
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case bool:
+			testBooleanObject(t, evaluated, expected)
+		case string:
+			testErrorObject(t, evaluated, expected)
+		}
+	}
+}
+
+func TestFunctionArity(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"let add = fn(x, y) { x + y; }; add(1);", "wrong number of arguments: got 1, want 2"},
+		{"let add = fn(x, y) { x + y; }; add();", "wrong number of arguments: got 0, want 2"},
+		{"let add = fn(x, y) { x + y; }; add(1, 2, 3);", "wrong number of arguments: got 3, want 2"},
+		{"fn() { 1 }(1)", "wrong number of arguments: got 1, want 0"},
+	}
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		errObj, ok := evaluated.(*object.Error)
+		if !ok {
+			t.Errorf("object is not Error. got=%T (%+v)", evaluated, evaluated)
+			continue
+		}
+		assert.Equal(t, tt.expected, errObj.Message)
+	}
+}
+
+func TestFunctionDefaultParameters(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"let f = fn(x, y = 10) { x + y }; f(5)", 15},
+		{"let f = fn(x, y = 10) { x + y }; f(5, 1)", 6},
+		{"let f = fn(x = 1, y = 2) { x * 10 + y }; f()", 12},
+		{"let f = fn(x = 1, y = 2) { x * 10 + y }; f(3)", 32},
+		{"let f = fn(x = 1, y = 2) { x * 10 + y }; f(3, 4)", 34},
+		{"let f = fn(x, y = x * 2) { x + y }; f(3)", 9}, // defaults can use previous params
+		{"let n = 7; let f = fn(x = n) { x }; f()", 7},  // ...and the closure env
+		{"let f = fn(x, y = 10) { x + y }; f()", "wrong number of arguments: got 0, want 1 to 2"},
+		{"let f = fn(x, y = 10) { x + y }; f(1, 2, 3)", "wrong number of arguments: got 3, want 1 to 2"},
+		{"let f = fn(x = foo) { x }; f()", "identifier not found: foo"},
+	}
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case string:
+			errObj, ok := evaluated.(*object.Error)
+			if !ok {
+				t.Errorf("object is not Error. got=%T (%+v)", evaluated, evaluated)
+				continue
+			}
+			assert.Equal(t, expected, errObj.Message)
+		}
+	}
+}
+
 func TestMapFunction(t *testing.T) {
 	tests := []struct {
 		input    string
@@ -332,6 +767,117 @@ func TestWhileExpression(t *testing.T) {
 	}
 }
 
+func TestDoWhileExpression(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected int
+	}{
+		{`let i = 0; do { i = i + 1 } while (i < 3); i`, 3},
+		// the body runs once, even if the condition is false right away
+		{`let i = 10; do { i = i + 1 } while (i < 3); i`, 11},
+		{`let n = 0; do { n = n + 1 } while (false); n`, 1},
+	}
+	for _, tt := range tests {
+		testIntegerObject(t, testEval(tt.input), int64(tt.expected))
+	}
+	testNullObject(t, testEval(`do { 1 } while (false)`))
+	testErrorObject(t, testEval(`do { 1 } while (1 + true)`), "type mismatch: INTEGER + BOOLEAN")
+}
+
+func TestLetAndReassignmentScopes(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		// `let` inside a block stays there...
+		{`if (true) { let y = 1 }; y`, "identifier not found: y"},
+		{`let i = 0; while (i < 1) { let y = 1; i = i + 1 }; y`, "identifier not found: y"},
+		{`let x = 1; if (true) { let x = 2 }; x`, 1},
+		{`let x = 1; if (true) { let x = 2; x }`, 2},
+		// ...while `=` updates the enclosing binding
+		{`let x = 1; if (true) { x = 2 }; x`, 2},
+		{`let x = 1; let f = fn() { x = x + 1 }; f(); f(); x`, 3},
+		{`let x = 1; if (true) { let x = 2; x = 3 }; x`, 1}, // the inner x
+		// `let` re-declares in the same scope
+		{`let x = 1; let x = x + 1; x`, 2},
+		{`y = 1`, "identifier not found: y"},
+		// assignment is an expression, and it chains
+		{`let a = 0; let b = 0; a = b = 4; a + b`, 8},
+		{`let x = 0; let r = (x = 5); r + x`, 10},
+		{`let x = 1; x = x == 1; if (x) { 7 } else { 0 }`, 7},
+	}
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case string:
+			testErrorObject(t, evaluated, expected)
+		}
+	}
+}
+
+func TestWhileConditionError(t *testing.T) {
+	testErrorObject(t, testEval(`while (1 + true) { }`), "type mismatch: INTEGER + BOOLEAN")
+	// it's checked at every iteration, not only the first one
+	input := `let i = 0; while (i < 3) { i = i + 1; if (i == 2) { i = "two" } }; i`
+	testErrorObject(t, testEval(input), "type mismatch: STRING < INTEGER")
+	// and it stops the program
+	testErrorObject(t, testEval(`while (x) { }; 5`), "identifier not found: x")
+}
+
+func TestWhileElse(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected int
+	}{
+		// zero iterations: else runs
+		{`let i = 5; while (i < 3) { i = i + 1 } else { 42 }`, 42},
+		{`let i = 5; while (i < 3) { i = i + 1 } else { i = 0 }; i`, 0},
+		// the body ran: else doesn't
+		{`let i = 0; while (i < 3) { i = i + 1 } else { i = 100 }; i`, 3},
+	}
+	for _, tt := range tests {
+		testIntegerObject(t, testEval(tt.input), int64(tt.expected))
+	}
+	testNullObject(t, testEval(`let i = 0; while (i < 3) { i = i + 1 } else { 42 }`))
+}
+
+func TestRecursionDepth(t *testing.T) {
+	// deep but finite recursion is fine
+	input := `let sum = fn(n) { if (n == 0) { return 0 } n + sum(n - 1) }; sum(1000)`
+	testIntegerObject(t, testEval(input), 500500)
+
+	// runaway recursion is stopped
+	evaluated := testEval(`let f = fn() { f() }; f()`)
+	errObj, ok := evaluated.(*object.Error)
+	if !ok {
+		t.Fatalf("object is not Error. got=%T (%+v)", evaluated, evaluated)
+	}
+	assert.Equal(t, "maximum recursion depth exceeded", errObj.Message)
+	assert.Equal(t, 0, callDepth) // and the depth is back to 0 afterwards
+
+	// the limit can be configured
+	MaxCallDepth = 10
+	defer func() { MaxCallDepth = 10000 }()
+	assert.IsType(t, &object.Error{}, testEval(input))
+}
+
+func TestWhileTruthiness(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected int
+	}{
+		// loop while the array has a last element (null is falsy)
+		{`let xs = [1]; let n = 0; while (last(xs)) { xs = []; n = n + 1 }; n`, 1},
+		{`let s = "x"; let n = 0; while (s) { s = false; n = n + 1 }; n`, 1},
+		{`let n = 0; while (last([])) { n = n + 1 }; n`, 0},
+	}
+	for _, tt := range tests {
+		testIntegerObject(t, testEval(tt.input), int64(tt.expected))
+	}
+}
+
 func TestClosures(t *testing.T) {
 	input := `
    let newAdder = fn(x) {
@@ -342,6 +888,31 @@ func TestClosures(t *testing.T) {
 	testIntegerObject(t, testEval(input), 3)
 }
 
+func TestClosuresMutatingCapturedVariables(t *testing.T) {
+	input := `
+	let counter = fn() { let c = 0; fn() { c = c + 1; c } };
+	let next = counter();
+	[next(), next(), next()]`
+	testIntegerArray(t, testEval(input), []int{1, 2, 3})
+
+	// every call to counter has a c of its own
+	input = `
+	let counter = fn() { let c = 0; fn() { c = c + 1; c } };
+	let a = counter();
+	let b = counter();
+	a(); a();
+	[a(), b()]`
+	testIntegerArray(t, testEval(input), []int{3, 1})
+
+	// and closures sharing a variable see each other's updates
+	input = `
+	let pair = fn() { let n = 0; [fn() { n = n + 1 }, fn() { n }] };
+	let [inc, get] = pair();
+	inc(); inc();
+	get()`
+	testIntegerObject(t, testEval(input), 2)
+}
+
 func TestBuiltinFunctions(t *testing.T) {
 	tests := []struct {
 		input    string
@@ -355,6 +926,10 @@ func TestBuiltinFunctions(t *testing.T) {
 		{`let array = [1,2,3]; len(array)`, 3},
 		{`let array = []; len(array)`, 0},
 		{`len([1])`, 1},
+		{`len({"a": 1, "b": 2})`, 2},
+		{`len({})`, 0},
+		{`len({1: 1, true: 2, "1": 3})`, 3},
+		{`len(true)`, "argument to `len` not supported, got BOOLEAN"},
 		{`last([1,2,3])`, 3},
 		{`last([])`, NULL},
 	}
@@ -382,41 +957,912 @@ func TestBuiltinFunctions(t *testing.T) {
 	}
 }
 
-func TestArrayLiterals(t *testing.T) {
-	input := "[1, 2 * 2, 3 + 3]"
-	evaluated := testEval(input)
-
-	result, ok := evaluated.(*object.Array)
-	assert.True(t, ok)
-	assert.Len(t, result.Elements, 3)
-	testIntegerObject(t, result.Elements[0], 1)
-	testIntegerObject(t, result.Elements[1], 4)
-	testIntegerObject(t, result.Elements[2], 6)
-}
-
-func TestHashLiterals(t *testing.T) {
-	input := `{"one": 1, "two": 2, "three": "three", "true": true}`
-	evaluated := testEval(input)
-
-	result, ok := evaluated.(*object.HashMap)
-	assert.True(t, ok)
-
-	expected := map[string]interface{}{
-		"one":   1,
-		"two":   2,
-		"three": "three",
-		"true":  true,
-	}
-	assert.ObjectsAreEqualValues(expected, result)
+func TestBareBuiltin(t *testing.T) {
+	evaluated := testEval(`len`)
+	assert.Equal(t, object.ObjectType(object.BUILTIN_OBJ), evaluated.Type())
+	assert.Equal(t, "builtin function", evaluated.Inspect())
 
+	// same when it's bound to something else
+	evaluated = testEval(`let f = puts; f`)
+	assert.Equal(t, "builtin function", evaluated.Inspect())
 }
 
-func TestArrayIndexExpressions(t *testing.T) {
+func TestMethodCalls(t *testing.T) {
 	tests := []struct {
 		input    string
 		expected interface{}
 	}{
-		{
+		{`"abc".len()`, 3},
+		{`[1, 2, 3].len()`, 3},
+		{`[1, 2, 3].push(4).len()`, 4},
+		{`let a = [1, 2]; a.push(3); a.len()`, 2}, // push returns a new array
+		{`[7, 8].first()`, 7},
+		{`[].first()`, NULL},
+		{`let len = fn(x) { 0 }; "abc".len()`, 3}, // always the builtin
+		{`let a = [1]; a.push(2)`, []int{1, 2}},
+		{`first([5, 6])`, 5},
+		{`push([], 1)`, []int{1}},
+		// errors
+		{`"abc".foo()`, "undefined method foo for STRING"},
+		{`let a = 1; a.contains(1)`, "undefined method contains for INTEGER"},
+		{`5.len()`, "argument to `len` not supported, got INTEGER"},
+		{`"abc".push(1)`, "argument to `push` must be ARRAY, got STRING"},
+		{`[1].push()`, "wrong number of arguments. got=1, want=2"},
+		{`x.len()`, "identifier not found: x"},
+	}
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case []int:
+			testIntegerArray(t, evaluated, expected)
+		case string:
+			testErrorObject(t, evaluated, expected)
+		case *object.Null:
+			testNullObject(t, evaluated)
+		}
+	}
+}
+
+func TestContains(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		// strings
+		{`contains("hello world", "o w")`, true},
+		{`contains("hello", "")`, true},
+		{`contains("hello", "Hello")`, false},
+		{`contains("", "a")`, false},
+		// arrays
+		{`contains([1, 2, 3], 2)`, true},
+		{`contains([1, 2, 3], 4)`, false},
+		{`contains([1, "2", 3], 2)`, false},
+		{`contains(["a", [1, 2]], [1, 2])`, true},
+		{`contains([], 1)`, false},
+		// hashmaps
+		{`contains({"a": 1}, "a")`, true},
+		{`contains({"a": 1}, "b")`, false},
+		{`contains({"a": 1}, 1)`, false},
+		// errors
+		{`contains(1, 1)`, "argument to `contains` not supported, got INTEGER"},
+		{`contains("abc", 1)`, "second argument to `contains` must be STRING, got INTEGER"},
+		{`contains([1])`, "wrong number of arguments. got=1, want=2"},
+	}
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		switch expected := tt.expected.(type) {
+		case bool:
+			testBooleanObject(t, evaluated, expected)
+		case string:
+			errObj, ok := evaluated.(*object.Error)
+			if !ok {
+				t.Errorf("object is not Error. got=%T (%+v)", evaluated, evaluated)
+				continue
+			}
+			assert.Equal(t, expected, errObj.Message)
+		}
+	}
+}
+
+func TestMathBuiltins(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{`pow(2, 10)`, 1024},
+		{`pow(-3, 3)`, -27},
+		{`pow(5, 0)`, 1},
+		{`pow(0, 0)`, 1},
+		{`pow(2, -1)`, 0.5},
+		{`pow(2.5, 2)`, 6.25},
+		{`pow(4, 0.5)`, 2.0},
+		{`pow(2, 62)`, 4611686018427387904},
+		{`pow(2, 63)`, "integer overflow"},
+		{`pow(10, 100)`, "integer overflow"},
+		{`sqrt(16)`, 4},
+		{`sqrt(0)`, 0},
+		{`sqrt(9007199136250225)`, 94906265},
+		{`sqrt(2)`, math.Sqrt2},
+		{`sqrt(6.25)`, 2.5},
+		{`sqrt(16.0)`, 4.0}, // a float stays a float
+		{`sqrt(-1)`, "cannot take the square root of a negative number"},
+		{`floor(3.7)`, 3},
+		{`floor(-3.2)`, -4},
+		{`floor(3)`, 3},
+		{`ceil(3.2)`, 4},
+		{`ceil(-3.7)`, -3},
+		{`ceil(3.0)`, 3},
+		{`floor(1e300)`, "integer overflow"},
+		// errors
+		{`pow(2)`, "wrong number of arguments. got=1, want=2"},
+		{`pow("2", 2)`, "arguments to `pow` must be numbers, got STRING and INTEGER"},
+		{`sqrt([])`, "argument to `sqrt` must be a number, got ARRAY"},
+		{`floor("1")`, "argument to `floor` must be a number, got STRING"},
+		{`ceil(1, 2)`, "wrong number of arguments. got=2, want=1"},
+	}
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case float64:
+			testFloatObject(t, evaluated, expected)
+		case string:
+			testErrorObject(t, evaluated, expected)
+		}
+	}
+}
+
+func TestStartsEndsWith(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{`startsWith("hello", "he")`, true},
+		{`startsWith("hello", "lo")`, false},
+		{`startsWith("hello", "")`, true},
+		{`startsWith("", "")`, true},
+		{`startsWith("he", "hello")`, false},
+		{`endsWith("hello", "lo")`, true},
+		{`endsWith("hello", "he")`, false},
+		{`endsWith("hello", "")`, true},
+		{`endsWith("lo", "hello")`, false},
+		// errors
+		{`startsWith("hello")`, "wrong number of arguments. got=1, want=2"},
+		{`startsWith(1, "a")`, "arguments to `startsWith` must be STRING, got INTEGER"},
+		{`endsWith("a", ["a"])`, "arguments to `endsWith` must be STRING, got ARRAY"},
+	}
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		switch expected := tt.expected.(type) {
+		case bool:
+			testBooleanObject(t, evaluated, expected)
+		case string:
+			testErrorObject(t, evaluated, expected)
+		}
+	}
+}
+
+func TestReplace(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`replace("a.b.c", ".", "-")`, "a-b-c"},
+		{`replace("aaa", "a", "bb")`, "bbbbbb"},
+		{`replace("hello world", "o", "")`, "hell wrld"},
+		{`replace("hello", "x", "y")`, "hello"},
+		{`replace("", "x", "y")`, ""},
+		{`let s = "a-b"; replace(s, "-", "+"); s`, "a-b"},
+	}
+	for _, tt := range tests {
+		testStringObject(t, testEval(tt.input), tt.expected)
+	}
+	testErrorObject(t, testEval(`replace("abc", "", "-")`), "cannot `replace` an empty string")
+	testErrorObject(t, testEval(`replace("abc", "a")`), "wrong number of arguments. got=2, want=3")
+	testErrorObject(t, testEval(`replace("abc", "a", 1)`), "arguments to `replace` must be STRING, got INTEGER")
+}
+
+func TestSubstr(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`substr("hello", 1, 3)`, "el"},
+		{`substr("hello", 0, 5)`, "hello"},
+		{`substr("hello", 2, 2)`, ""},
+		{`substr("héllo", 1, 2)`, "é"},
+		// clamped
+		{`substr("hello", 3, 100)`, "lo"},
+		{`substr("hello", -100, 2)`, "he"},
+		{`substr("hello", 4, 1)`, ""},
+		{`substr("hello", 10, 20)`, ""},
+		{`substr("", 0, 1)`, ""},
+		// negative indices
+		{`substr("hello", -3, 5)`, "llo"},
+		{`substr("hello", 0, -1)`, "hell"},
+		{`substr("hello", -4, -2)`, "el"},
+	}
+	for _, tt := range tests {
+		testStringObject(t, testEval(tt.input), tt.expected)
+	}
+	testErrorObject(t, testEval(`substr("hello", 1)`), "wrong number of arguments. got=2, want=3")
+	testErrorObject(t, testEval(`substr(1, 1, 2)`), "first argument to `substr` must be STRING, got INTEGER")
+	testErrorObject(t, testEval(`substr("hello", "1", 2)`), "bounds of `substr` must be INTEGER, got STRING and INTEGER")
+}
+
+func TestIndexOf(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		// strings
+		{`indexOf("hello", "l")`, 2},
+		{`indexOf("hello", "lo")`, 3},
+		{`indexOf("hello", "")`, 0},
+		{`indexOf("hello", "x")`, -1},
+		{`indexOf("héllo", "l")`, 2},
+		// arrays
+		{`indexOf([10, 20, 30], 20)`, 1},
+		{`indexOf([10, 20, 20], 20)`, 1},
+		{`indexOf([10, 20, 30], 40)`, -1},
+		{`indexOf([1, [2, 3]], [2, 3])`, 1},
+		{`indexOf([1, 2], "1")`, -1},
+		{`indexOf([], 1)`, -1},
+		// errors
+		{`indexOf(1, 1)`, "argument to `indexOf` not supported, got INTEGER"},
+		{`indexOf("abc", 1)`, "second argument to `indexOf` must be STRING, got INTEGER"},
+		{`indexOf([1])`, "wrong number of arguments. got=1, want=2"},
+	}
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case string:
+			testErrorObject(t, evaluated, expected)
+		}
+	}
+}
+
+func TestNumericBuiltins(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{`abs(-5)`, 5},
+		{`abs(5)`, 5},
+		{`abs(0)`, 0},
+		{`abs(-9223372036854775807)`, 9223372036854775807},
+		{`abs(-9223372036854775807 - 1)`, "integer overflow"},
+		{`abs("5")`, "argument to `abs` must be a number, got STRING"},
+		{`abs()`, "wrong number of arguments. got=0, want=1"},
+		// many arguments
+		{`min(3, 1, 2)`, 1},
+		{`max(3, 1, 2)`, 3},
+		{`min(-3)`, -3},
+		// a single array
+		{`min([3, 1, 2])`, 1},
+		{`max([3, 1, 2])`, 3},
+		{`max([4])`, 4},
+		// errors
+		{`min()`, "`min` needs at least one number"},
+		{`max([])`, "`max` needs at least one number"},
+		{`min(1, "2")`, "arguments to `min` must be numbers, got STRING"},
+		{`max([1, true])`, "arguments to `max` must be numbers, got BOOLEAN"},
+		{`max("abc")`, "arguments to `max` must be numbers, got STRING"},
+		{`min([1], [2])`, "arguments to `min` must be numbers, got ARRAY"},
+	}
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case string:
+			errObj, ok := evaluated.(*object.Error)
+			if !ok {
+				t.Errorf("object is not Error. got=%T (%+v)", evaluated, evaluated)
+				continue
+			}
+			assert.Equal(t, expected, errObj.Message)
+		}
+	}
+}
+
+func TestReverse(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{`reverse([1, 2, 3])`, []int{3, 2, 1}},
+		{`reverse([1])`, []int{1}},
+		{`reverse([])`, []int{}},
+		{`let a = [1, 2]; reverse(a); a`, []int{1, 2}},
+		{`reverse("abc")`, "cba"},
+		{`reverse("")`, ""},
+		{`reverse("héllo")`, "olléh"},
+	}
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		switch expected := tt.expected.(type) {
+		case []int:
+			testIntegerArray(t, evaluated, expected)
+		case string:
+			testStringObject(t, evaluated, expected)
+		}
+	}
+
+	testErrorObject(t, testEval(`reverse(1)`), "argument to `reverse` not supported, got INTEGER")
+	testErrorObject(t, testEval(`reverse([1], [2])`), "wrong number of arguments. got=2, want=1")
+}
+
+func TestChars(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected []string
+	}{
+		{`chars("abc")`, []string{"a", "b", "c"}},
+		{`chars("")`, []string{}},
+		{`chars("héllo")`, []string{"h", "é", "l", "l", "o"}}, // runes, not bytes
+		{`chars("日本")`, []string{"日", "本"}},
+		{`let out = ""; for c in chars("abc") { out = c + out }; chars(out)`, []string{"c", "b", "a"}},
+	}
+	for _, tt := range tests {
+		array, ok := testEval(tt.input).(*object.Array)
+		if !ok {
+			t.Errorf("object is not Array for %q", tt.input)
+			continue
+		}
+		assert.Len(t, array.Elements, len(tt.expected))
+		for i, ex := range tt.expected {
+			testStringObject(t, array.Elements[i], ex)
+		}
+	}
+
+	testErrorObject(t, testEval(`chars(1)`), "argument to `chars` must be STRING, got INTEGER")
+}
+
+func TestEnumerate(t *testing.T) {
+	evaluated := testEval(`enumerate(["a", "b"])`)
+	pairs, ok := evaluated.(*object.Array)
+	if !ok {
+		t.Fatalf("object is not Array. got=%T (%+v)", evaluated, evaluated)
+	}
+	assert.Len(t, pairs.Elements, 2)
+	assert.Equal(t, `[[0, "a"], [1, "b"]]`, pairs.Inspect())
+
+	tests := []struct {
+		input    string
+		expected int
+	}{
+		// sum of the indices, and of the values
+		{`let n = 0; for p in enumerate([5, 6, 7]) { n = n + p[0] }; n`, 3},
+		{`let n = 0; for p in enumerate([5, 6, 7]) { let [i, x] = p; n = n + x }; n`, 18},
+		{`let n = 0; for p in enumerate([5, 6, 7]) { let [i, x] = p; n = n + i * x }; n`, 20},
+		{`enumerate([]).len()`, 0},
+	}
+	for _, tt := range tests {
+		testIntegerObject(t, testEval(tt.input), int64(tt.expected))
+	}
+	testErrorObject(t, testEval(`enumerate("abc")`), "argument to `enumerate` must be ARRAY, got STRING")
+}
+
+func TestClone(t *testing.T) {
+	evaluated := testEval(`let a = [1, [2, 3], {"k": [4]}]; clone(a)`)
+	assert.Equal(t, `[1, [2, 3], {"k": [4]}]`, evaluated.Inspect())
+	assert.True(t, objectsEqual(evaluated, testEval(`[1, [2, 3], {"k": [4]}]`)))
+
+	// mutating the clone, at any depth, leaves the original alone
+	env := object.NewEnvironment()
+	original := Eval(parser.New(lexer.New(`let a = [1, [2, 3], {"k": [4]}]; a`)).ParseProgram(), env)
+	cloned := Eval(parser.New(lexer.New(`clone(a)`)).ParseProgram(), env).(*object.Array)
+	cloned.Elements[0] = &object.Integer{Value: 10}
+	cloned.Elements[1].(*object.Array).Elements[0] = &object.Integer{Value: 20}
+	k, n := &object.String{Value: "k"}, &object.String{Value: "new"}
+	cloned.Elements[2].(*object.HashMap).Pairs[k.HashKey()].Value.(*object.Array).Elements[0] = &object.Integer{Value: 40}
+	cloned.Elements[2].(*object.HashMap).Pairs[n.HashKey()] = object.HashPair{Key: n, Value: TRUE}
+	assert.Equal(t, `[1, [2, 3], {"k": [4]}]`, original.Inspect())
+	assert.Equal(t, `[10, [20, 3], {"k": [40], "new": true}]`, cloned.Inspect())
+
+	// scalars are shared
+	original = Eval(parser.New(lexer.New(`let b = ["s"]; b`)).ParseProgram(), env)
+	cloned = Eval(parser.New(lexer.New(`clone(b)`)).ParseProgram(), env).(*object.Array)
+	assert.Same(t, original.(*object.Array).Elements[0], cloned.Elements[0])
+
+	testErrorObject(t, testEval(`clone(1)`), "argument to `clone` must be ARRAY or HASHMAP, got INTEGER")
+	testErrorObject(t, testEval(`clone(len)`), "argument to `clone` must be ARRAY or HASHMAP, got BUILTIN")
+}
+
+func TestFlatten(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`flatten([[1, 2], [3, [4]]], 1)`, `[1, 2, 3, [4]]`},
+		{`flatten([[1, 2], [3, [4]]])`, `[1, 2, 3, 4]`},
+		{`flatten([[1, [2, [3, [4]]]]], 2)`, `[1, 2, [3, [4]]]`},
+		{`flatten([[1, [2]]], 0)`, `[[1, [2]]]`},
+		{`flatten([1, 2, 3])`, `[1, 2, 3]`},
+		{`flatten([[], [[]], 1])`, `[1]`},
+		{`flatten([])`, `[]`},
+		// the original is left untouched
+		{`let a = [[1], [2]]; flatten(a); a`, `[[1], [2]]`},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.expected, testEval(tt.input).Inspect(), tt.input)
+	}
+	testErrorObject(t, testEval(`flatten("abc")`), "argument to `flatten` must be ARRAY, got STRING")
+	testErrorObject(t, testEval(`flatten([1], "a")`), "depth of `flatten` must be INTEGER, got STRING")
+	testErrorObject(t, testEval(`flatten([1], -1)`), "depth of `flatten` must not be negative, got -1")
+	testErrorObject(t, testEval(`flatten()`), "wrong number of arguments. got=0, want=1 or 2")
+}
+
+func TestUnique(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`unique([1, 2, 2, 3, 1])`, `[1, 2, 3]`},
+		{`unique(["b", "a", "b", "c", "a"])`, `["b", "a", "c"]`},
+		{`unique([3, 1, 2])`, `[3, 1, 2]`},
+		{`unique([])`, `[]`},
+		// same equality as ==
+		{`unique([1, 1.0, "1"])`, `[1, "1"]`},
+		{`unique([[1, 2], [1, 2], {"a": 1}, {"a": 1}])`, `[[1, 2], {"a": 1}]`},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.expected, testEval(tt.input).Inspect(), tt.input)
+	}
+	testErrorObject(t, testEval(`unique("aab")`), "argument to `unique` must be ARRAY, got STRING")
+}
+
+func TestTakeDrop(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`take([1, 2, 3, 4], 2)`, `[1, 2]`},
+		{`drop([1, 2, 3, 4], 2)`, `[3, 4]`},
+		{`take([1, 2, 3], 0)`, `[]`},
+		{`drop([1, 2, 3], 0)`, `[1, 2, 3]`},
+		{`take([1, 2, 3], 10)`, `[1, 2, 3]`},
+		{`drop([1, 2, 3], 10)`, `[]`},
+		{`take([], 1)`, `[]`},
+		// the original is left untouched
+		{`let a = [1, 2]; take(a, 1); drop(a, 1); a`, `[1, 2]`},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.expected, testEval(tt.input).Inspect(), tt.input)
+	}
+	testErrorObject(t, testEval(`take([1], -1)`), "second argument to `take` must not be negative, got -1")
+	testErrorObject(t, testEval(`drop([1], -1)`), "second argument to `drop` must not be negative, got -1")
+	testErrorObject(t, testEval(`take("abc", 1)`), "first argument to `take` must be ARRAY, got STRING")
+	testErrorObject(t, testEval(`drop([1], "1")`), "second argument to `drop` must be INTEGER, got STRING")
+	testErrorObject(t, testEval(`take([1])`), "wrong number of arguments. got=1, want=2")
+}
+
+func TestCount(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`count([1, 1, 2, 3, 3, 3])`, `{1: 2, 2: 1, 3: 3}`},
+		{`count(["b", "a", "b"])`, `{"a": 1, "b": 2}`},
+		{`count([true, 1, "1", true])`, `{"1": 1, 1: 1, true: 2}`}, // keys are typed
+		{`count([])`, `{}`},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.expected, testEval(tt.input).Inspect(), tt.input)
+	}
+	testIntegerObject(t, testEval(`count(chars("banana"))["a"]`), 3)
+	testErrorObject(t, testEval(`count([1, [2]])`), "unusable as hash key: ARRAY")
+	testErrorObject(t, testEval(`count([1.5])`), "unusable as hash key: FLOAT")
+	testErrorObject(t, testEval(`count("abc")`), "argument to `count` must be ARRAY, got STRING")
+}
+
+func TestGroupBy(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`group_by(fn(x) { mod(x, 2) }, [1, 2, 3, 4])`, `{0: [2, 4], 1: [1, 3]}`},
+		{`group_by(len, ["a", "bb", "c", "dd", "eee"])`, `{1: ["a", "c"], 2: ["bb", "dd"], 3: ["eee"]}`},
+		{`group_by(fn(x) { x > 2 }, [1, 2, 3])`, `{false: [1, 2], true: [3]}`},
+		{`group_by(fn(x) { x }, [])`, `{}`},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.expected, testEval(tt.input).Inspect(), tt.input)
+	}
+	testErrorObject(t, testEval(`group_by(fn(x) { [x] }, [1])`), "unusable as hash key: ARRAY")
+	testErrorObject(t, testEval(`group_by(fn(x) { x + true }, [1])`), "type mismatch: INTEGER + BOOLEAN")
+	testErrorObject(t, testEval(`group_by(len, "abc")`), "second argument to `group_by` must be ARRAY, got STRING")
+}
+
+func TestRadixFormatting(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`hex(255)`, "0xff"},
+		{`oct(8)`, "0o10"},
+		{`bin(5)`, "0b101"},
+		{`hex(0)`, "0x0"},
+		{`oct(0)`, "0o0"},
+		{`bin(0)`, "0b0"},
+		{`hex(-255)`, "-0xff"},
+		{`bin(-5)`, "-0b101"},
+		{`hex(-9223372036854775807 - 1)`, "-0x8000000000000000"},
+		{`hex(9223372036854775807)`, "0x7fffffffffffffff"},
+	}
+	for _, tt := range tests {
+		testStringObject(t, testEval(tt.input), tt.expected)
+	}
+	testErrorObject(t, testEval(`hex("ff")`), "argument to `hex` must be INTEGER, got STRING")
+	testErrorObject(t, testEval(`bin(1.5)`), "argument to `bin` must be INTEGER, got FLOAT")
+	testErrorObject(t, testEval(`oct()`), "wrong number of arguments. got=0, want=1")
+}
+
+func TestChrOrd(t *testing.T) {
+	testStringObject(t, testEval(`chr(65)`), "A")
+	testStringObject(t, testEval(`chr(233)`), "é")
+	testStringObject(t, testEval(`chr(8364)`), "€")
+	testIntegerObject(t, testEval(`ord("A")`), 65)
+	testIntegerObject(t, testEval(`ord("€")`), 8364) // 3 bytes, one code point
+	testStringObject(t, testEval(`chr(ord("a") + 1)`), "b")
+
+	testErrorObject(t, testEval(`chr(-1)`), "argument to `chr` is not a valid code point: -1")
+	testErrorObject(t, testEval(`chr(1114112)`), "argument to `chr` is not a valid code point: 1114112")
+	testErrorObject(t, testEval(`chr(55296)`), "argument to `chr` is not a valid code point: 55296") // a surrogate
+	testErrorObject(t, testEval(`chr("A")`), "argument to `chr` must be INTEGER, got STRING")
+	testErrorObject(t, testEval(`ord("AB")`), "argument to `ord` must be a single character, got \"AB\"")
+	testErrorObject(t, testEval(`ord("")`), "argument to `ord` must be a single character, got \"\"")
+	testErrorObject(t, testEval(`ord(65)`), "argument to `ord` must be STRING, got INTEGER")
+}
+
+func TestMapValues(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`map_values(fn(v) { v * 2 }, {"a": 1, "b": 2})`, `{"a": 2, "b": 4}`},
+		{`map_values(fn(v) { v * 2 }, {})`, `{}`},
+		{`map_values(fn(v) { len(v) }, {1: "one", true: "three"})`, `{1: 3, true: 5}`},
+		{`map_values(str, {"x": 1})`, `{"x": "1"}`}, // builtins work too
+		// the original is left untouched
+		{`let h = {"a": 1}; map_values(fn(v) { v + 1 }, h); h`, `{"a": 1}`},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.expected, testEval(tt.input).Inspect(), tt.input)
+	}
+	testErrorObject(t, testEval(`map_values(fn(v) { v }, [1])`), "second argument to `map_values` must be HASHMAP, got ARRAY")
+	testErrorObject(t, testEval(`map_values(fn(v) { v + true }, {"a": 1})`), "type mismatch: INTEGER + BOOLEAN")
+	testErrorObject(t, testEval(`map_values(1, {"a": 1})`), "not a function: INTEGER")
+}
+
+func TestTypePredicates(t *testing.T) {
+	values := []string{`"s"`, `1`, `1.5`, `[1]`, `{"k": 1}`, `fn(x) { x }`, `len`, `true`, `last([])`}
+	// which of the values each predicate accepts
+	tests := []struct {
+		predicate string
+		accepted  []string
+	}{
+		{"is_string", []string{`"s"`}},
+		{"is_number", []string{`1`, `1.5`}},
+		{"is_array", []string{`[1]`}},
+		{"is_hash", []string{`{"k": 1}`}},
+		{"is_function", []string{`fn(x) { x }`, `len`}},
+	}
+	for _, tt := range tests {
+		for _, v := range values {
+			expected := false
+			for _, a := range tt.accepted {
+				expected = expected || a == v
+			}
+			evaluated := testEval(fmt.Sprintf("%s(%s)", tt.predicate, v))
+			if !testBooleanObject(t, evaluated, expected) {
+				t.Errorf("%s(%s)", tt.predicate, v)
+			}
+		}
+		testErrorObject(t, testEval(tt.predicate+"(1, 2)"), "wrong number of arguments. got=2, want=1")
+	}
+}
+
+func TestToJSON(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`to_json(1)`, `1`},
+		{`to_json(-1.5)`, `-1.5`},
+		{`to_json(true)`, `true`},
+		{`to_json(last([]))`, `null`},
+		{"to_json(\"tab\tünicode\")", `"tab\tünicode"`}, // escaped as needed
+		{`to_json([])`, `[]`},
+		{`to_json({})`, `{}`},
+		{`to_json([1, "two", [3, false]])`, `[1,"two",[3,false]]`},
+		// sorted keys, always strings
+		{`to_json({"b": 1, "a": [{"c": last([])}]})`, `{"a":[{"c":null}],"b":1}`},
+		{`to_json({1: "one", true: "yes"})`, `{"1":"one","true":"yes"}`},
+	}
+	for _, tt := range tests {
+		testStringObject(t, testEval(tt.input), tt.expected)
+	}
+	testErrorObject(t, testEval(`to_json(fn(x) { x })`), "cannot convert FUNCTION to JSON")
+	testErrorObject(t, testEval(`to_json([1, {"f": len}])`), "cannot convert BUILTIN to JSON")
+	testErrorObject(t, testEval(`to_json({1: "a", "1": "b"})`), `cannot convert to JSON: duplicate key "1"`)
+	testErrorObject(t, testEval(`to_json([{true: 1, "true": 2}])`), `cannot convert to JSON: duplicate key "true"`)
+	testErrorObject(t, testEval(`to_json()`), "wrong number of arguments. got=0, want=1")
+}
+
+func TestParseJSON(t *testing.T) {
+	tests := []struct {
+		json     string
+		expected string // Inspect() of the result
+	}{
+		{`1`, `1`},
+		{`-2.5`, `-2.5`},
+		{`1.0`, `1`}, // whole numbers are integers
+		{`1e3`, `1000`},
+		{`1e100`, `1e+100`},
+		{`true`, `true`},
+		{`null`, `null`},
+		{`"a\tb"`, `"a\tb"`},
+		{` [1, {"a": true}] `, `[1, {"a": true}]`},
+		{`{"b": [], "a": {"c": null}}`, `{"a": {"c": null}, "b": []}`},
+	}
+	for _, tt := range tests {
+		env := object.NewEnvironment()
+		env.Set("s", &object.String{Value: tt.json})
+		evaluated := Eval(parser.New(lexer.New(`parse_json(s)`)).ParseProgram(), env)
+		assert.Equal(t, tt.expected, evaluated.Inspect(), tt.json)
+	}
+
+	// and back
+	roundTrips := []string{
+		`[1, "two", [3, false], last([])]`,
+		`{"a": [{"b": 1.5}], "c": {}}`,
+		`{"users": [{"name": "ann", "age": 30}, {"name": "bob", "tags": ["x"]}]}`,
+	}
+	for _, input := range roundTrips {
+		testBooleanObject(t, testEval(fmt.Sprintf("let x = %s; parse_json(to_json(x)) == x", input)), true)
+	}
+
+	for _, malformed := range []string{`[1, 2`, `{"a" 1}`, `tru`, ``, `1 2`} {
+		env := object.NewEnvironment()
+		env.Set("s", &object.String{Value: malformed})
+		evaluated := Eval(parser.New(lexer.New(`parse_json(s)`)).ParseProgram(), env)
+		errObj, ok := evaluated.(*object.Error)
+		if assert.True(t, ok, malformed) {
+			assert.True(t, strings.HasPrefix(errObj.Message, "cannot parse JSON: "), errObj.Message)
+		}
+	}
+	testErrorObject(t, testEval(`parse_json(1)`), "argument to `parse_json` must be STRING, got INTEGER")
+}
+
+func TestSum(t *testing.T) {
+	testIntegerObject(t, testEval(`sum([1, 2, 3])`), 6)
+	testIntegerObject(t, testEval(`sum([-1, 1])`), 0)
+	testIntegerObject(t, testEval(`sum([])`), 0)
+	testIntegerObject(t, testEval(`let xs = [10, 20]; sum(xs + [30])`), 60)
+
+	testErrorObject(t, testEval(`sum([1, "2", 3])`), "elements of `sum` must be numbers, got STRING")
+	testErrorObject(t, testEval(`sum([1, [2]])`), "elements of `sum` must be numbers, got ARRAY")
+	testErrorObject(t, testEval(`sum(1)`), "argument to `sum` must be ARRAY, got INTEGER")
+	testErrorObject(t, testEval(`sum([9223372036854775807, 1])`), "integer overflow")
+}
+
+func TestFormat(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`format("x={}, y={}", 1, 2)`, "x=1, y=2"},
+		{`format("no placeholders")`, "no placeholders"},
+		{`format("{}", "str")`, "str"},
+		{`format("{}{}", [1, "a"], true)`, `[1, "a"]true`},
+		{`format("{{}} is {}", "empty")`, "{} is empty"},
+		{`format("{{{}}}", 1)`, "{1}"},
+		{`format("{{x}}")`, "{x}"},
+		{`format("é {}!", "ü")`, "é ü!"},
+	}
+	for _, tt := range tests {
+		testStringObject(t, testEval(tt.input), tt.expected)
+	}
+
+	errors := []struct {
+		input    string
+		expected string
+	}{
+		{`format("{} {}", 1)`, "wrong number of arguments for `format`: 2 placeholders, got 1 values"},
+		{`format("{}", 1, 2)`, "wrong number of arguments for `format`: 1 placeholders, got 2 values"},
+		{`format("", 1)`, "wrong number of arguments for `format`: 0 placeholders, got 1 values"},
+		{`format(1)`, "first argument to `format` must be STRING, got INTEGER"},
+		{`format()`, "wrong number of arguments. got=0, want at least 1"},
+	}
+	for _, tt := range errors {
+		testErrorObject(t, testEval(tt.input), tt.expected)
+	}
+}
+
+func TestArrayLiterals(t *testing.T) {
+	input := "[1, 2 * 2, 3 + 3]"
+	evaluated := testEval(input)
+
+	result, ok := evaluated.(*object.Array)
+	assert.True(t, ok)
+	assert.Len(t, result.Elements, 3)
+	testIntegerObject(t, result.Elements[0], 1)
+	testIntegerObject(t, result.Elements[1], 4)
+	testIntegerObject(t, result.Elements[2], 6)
+}
+
+func TestArrayConcatenation(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"[1, 2] + [3, 4]", []int{1, 2, 3, 4}},
+		{"[1, 2] + []", []int{1, 2}},
+		{"[] + [1, 2]", []int{1, 2}},
+		{"[] + []", []int{}},
+		{"let a = [1]; let b = a + [2]; a", []int{1}}, // a is left untouched
+		{"[1] + 2", "type mismatch: ARRAY + INTEGER"},
+		{"[1] - [2]", "unknown operator: ARRAY - ARRAY"},
+	}
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		switch expected := tt.expected.(type) {
+		case []int:
+			testIntegerArray(t, evaluated, expected)
+		case string:
+			errObj, ok := evaluated.(*object.Error)
+			if !ok {
+				t.Errorf("object is not Error. got=%T (%+v)", evaluated, evaluated)
+				continue
+			}
+			assert.Equal(t, expected, errObj.Message)
+		}
+	}
+}
+
+func TestArrayRepetition(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"[1, 2] * 3", []int{1, 2, 1, 2, 1, 2}},
+		{"[0] * 5", []int{0, 0, 0, 0, 0}},
+		{"[] * 5", []int{}},
+		{"[1, 2] * 0", []int{}},
+		{"let a = [1]; let b = a * 2; a", []int{1}}, // a is left untouched
+		{"[1] * -1", "cannot repeat an array a negative number of times: -1"},
+		{"[1, 2] * 9223372036854775807", "array too large: 2 * 9223372036854775807 elements"},
+		{"3 * [1]", "type mismatch: INTEGER * ARRAY"},
+		{"[1] * [2]", "unknown operator: ARRAY * ARRAY"},
+	}
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		switch expected := tt.expected.(type) {
+		case []int:
+			testIntegerArray(t, evaluated, expected)
+		case string:
+			testErrorObject(t, evaluated, expected)
+		}
+	}
+
+	// the elements are shared, not copied
+	arr := testEval("[[1]] * 2").(*object.Array)
+	assert.Same(t, arr.Elements[0], arr.Elements[1])
+}
+
+func TestDeepEquality(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected bool
+	}{
+		{"[1, 2] == [1, 2]", true},
+		{"[1, 2] != [1, 2]", false},
+		{"[1, 2] == [2, 1]", false},
+		{"[1, 2] == [1, 2, 3]", false},
+		{"[] == []", true},
+		{"[[1, [2]], 3] == [[1, [2]], 3]", true},
+		{"[[1, [2]], 3] == [[1, [3]], 3]", false},
+		{`["a", true] == ["a", true]`, true},
+		{"[1] == [1.0]", true},
+		{"let a = [1]; let b = a; a == b", true},
+		{`{"a": 1, "b": [2]} == {"b": [2], "a": 1}`, true},
+		{`{"a": 1} == {"a": 2}`, false},
+		{`{"a": 1} == {"b": 1}`, false},
+		{`{"a": 1} == {"a": 1, "b": 2}`, false},
+		{`{} == {}`, true},
+		{`{"a": {"b": [1]}} == {"a": {"b": [1]}}`, true},
+		// different types are never equal
+		{"[1] == 1", false},
+		{"[1] != 1", true},
+		{`1 == "1"`, false},
+		{"1 == true", false},
+		{`{} == []`, false},
+		{"1 == 1.0", true},
+		// functions, by identity
+		{"let f = fn() { 1 }; f == f", true},
+		{"fn() { 1 } == fn() { 1 }", false},
+	}
+	for _, tt := range tests {
+		testBooleanObject(t, testEval(tt.input), tt.expected)
+	}
+
+	// null only equals null. There's no null literal: n is null, from last([])
+	nulls := []struct {
+		input    string
+		expected bool
+	}{
+		{"n == n", true},
+		{"n == last([])", true},
+		{"n == 0", false},
+		{"n != false", true},
+		{`"" == n`, false},
+		{"[n] == [n]", true},
+	}
+	for _, tt := range nulls {
+		testBooleanObject(t, testEval("let n = last([]); "+tt.input), tt.expected)
+	}
+
+	// other operators still need the same type
+	testErrorObject(t, testEval("[1] + 1"), "type mismatch: ARRAY + INTEGER")
+	testErrorObject(t, testEval("[1] < [2]"), "unknown operator: ARRAY < ARRAY")
+	testErrorObject(t, testEval("let n = last([]); n + 1"), "type mismatch: NULL + INTEGER")
+}
+
+func TestHashLiterals(t *testing.T) {
+	input := `{"one": 1, "two": 2, "three": "three", "true": true}`
+	evaluated := testEval(input)
+
+	result, ok := evaluated.(*object.HashMap)
+	assert.True(t, ok)
+
+	expected := map[string]interface{}{
+		"one":   1,
+		"two":   2,
+		"three": "three",
+		"true":  true,
+	}
+	assert.ObjectsAreEqualValues(expected, result)
+
+}
+
+func TestHashKeys(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{`{1: "a"}[1]`, "a"},
+		{`{1: "a"}[2]`, nil},
+		{`{true: "b"}[true]`, "b"},
+		{`{true: "b"}[false]`, nil},
+		{`{1 + 1: "c"}[2]`, "c"},
+		{`{-1: "d"}[-1]`, "d"},
+		{`let k = "x"; {k: "e"}["x"]`, "e"},
+		// the type is part of the key: 1, true and "1" don't collide
+		{`let h = {1: "int", true: "bool", "1": "str"}; h[1] + h[true] + h["1"]`, "intboolstr"},
+		{`{1: "a"}[true]`, nil},
+		{`{true: "a"}[1]`, nil},
+		{`{"1": "a"}[1]`, nil},
+		// only some objects can be keys
+		{`{[1]: 2}`, "unusable as hash key: ARRAY"},
+		{`{fn(x) { x }: 2}`, "unusable as hash key: FUNCTION"},
+		{`{"a": 1}[[1]]`, "unusable as hash key: ARRAY"},
+		{`{1.5: 1}`, "unusable as hash key: FLOAT"},
+		{`{x: 1}`, "identifier not found: x"},
+		{`{1: x}`, "identifier not found: x"},
+	}
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		switch expected := tt.expected.(type) {
+		case string:
+			if errObj, ok := evaluated.(*object.Error); ok {
+				assert.Equal(t, expected, errObj.Message)
+			} else {
+				testStringObject(t, evaluated, expected)
+			}
+		default:
+			testNullObject(t, evaluated)
+		}
+	}
+
+	assert.Equal(t, `{"a": 1, 1: 2, true: 3}`, testEval(`{true: 3, 1: 2, "a": 1}`).Inspect())
+	testBooleanObject(t, testEval(`{1: "a", true: "b"} == {true: "b", 1: "a"}`), true)
+	testBooleanObject(t, testEval(`{1: "a"} == {true: "a"}`), false)
+	testBooleanObject(t, testEval(`contains({1: "a"}, 1)`), true)
+}
+
+func TestArrayIndexExpressions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{
 			"[1, 2, 3][0]",
 			1,
 		},
@@ -495,6 +1941,55 @@ func TestHashIndexExpressions(t *testing.T) {
 	}
 }
 
+func TestNegativeNumbersInArrays(t *testing.T) {
+	testIntegerArray(t, testEval(`[-1, -2, -3]`), []int{-1, -2, -3})
+	testIntegerArray(t, testEval(`[1, -2, 3 - -4]`), []int{1, -2, 7})
+	testIntegerObject(t, testEval(`[-1][0]`), -1)
+	testIntegerObject(t, testEval(`let a = [5]; -a[0]`), -5)
+	testIntegerObject(t, testEval(`sum([-1, -2])`), -3)
+}
+
+func TestNestedIndexExpressions(t *testing.T) {
+	data := `let data = {
+		"users": [
+			{"name": "ann", "tags": ["a", "b"]},
+			{"name": "bob", "tags": []}
+		]
+	};`
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{`data["users"][0]["name"]`, "ann"},
+		{`data["users"][1]["name"]`, "bob"},
+		{`data["users"][0]["tags"][1]`, "b"},
+		{`let i = 0; data["users"][i + 1]["name"]`, "bob"},
+		{`data["users"].len()`, 2},
+		// missing somewhere along the way: null, not an error
+		{`data["groups"][0]["name"]`, nil},
+		{`data["users"][5]["name"]`, nil},
+		{`data["users"][1]["tags"][0]`, nil},
+		{`data["users"][0]["age"]`, nil},
+		// indexing something that can't be indexed is still an error
+		{`data["users"][0]["name"][0]`, "index operator not supported: STRING"},
+	}
+	for _, tt := range tests {
+		evaluated := testEval(data + tt.input)
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case string:
+			if errObj, ok := evaluated.(*object.Error); ok {
+				assert.Equal(t, expected, errObj.Message)
+			} else {
+				testStringObject(t, evaluated, expected)
+			}
+		default:
+			testNullObject(t, evaluated)
+		}
+	}
+}
+
 func TestReassignmentExpressions(t *testing.T) {
 	tests := []struct {
 		input    string
@@ -540,6 +2035,8 @@ func TestForLoop(t *testing.T) {
 		{`let acc = 0; let xs = [10,20,30]; for i in [0,1,2] { acc = acc + xs[i] }; acc`, 60},
 		{`let acc = 0; for s in ["hello", "world"] { acc = acc + len(s) } acc`, 10},
 		{`let array = [1,2,3]; let acc = 0; for i in array { acc = acc + i }; acc`, 6},
+		{`let acc = 0; for i in [1, 2] + [3] { acc = acc + i }; acc`, 6},
+		{`let acc = 0; for i in [[1, 2], [3]][0] { acc = acc + i }; acc`, 3},
 		{`let x = true; let acc = 0; for i in x { acc = acc + i }; acc`, "I can only loop through arrays; got *object.Boolean instead"},
 	}
 	for _, tt := range tests {
@@ -560,6 +2057,392 @@ func TestForLoop(t *testing.T) {
 	}
 }
 
+func TestStringRendering(t *testing.T) {
+	tests := []struct {
+		input     string
+		inspected string // REPL echo
+		str       string // puts/str
+	}{
+		{`"hi"`, `"hi"`, `hi`},
+		{`["hi", 1]`, `["hi", 1]`, `["hi", 1]`},
+		{`[["hi"]]`, `[["hi"]]`, `[["hi"]]`},
+		{`{"a": "hi"}`, `{"a": "hi"}`, `{"a": "hi"}`},
+		{`1`, `1`, `1`},
+		{`true`, `true`, `true`},
+	}
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		assert.Equal(t, tt.inspected, evaluated.Inspect())
+		assert.Equal(t, tt.str, object.ToString(evaluated))
+		testStringObject(t, testEval("str("+tt.input+")"), tt.str)
+	}
+}
+
+func TestReadFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "hello.txt")
+	if err := os.WriteFile(path, []byte("hello\nworld"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	missing := filepath.Join(t.TempDir(), "missing.txt")
+
+	testStringObject(t, testEval(`read_file("`+path+`")`), "hello\nworld")
+
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`read_file("` + missing + `")`, "cannot read file: open " + missing + ": no such file or directory"},
+		{`read_file(1)`, "argument to `read_file` must be STRING, got INTEGER"},
+		{`read_file()`, "wrong number of arguments. got=0, want=1"},
+	}
+	for _, tt := range tests {
+		errObj, ok := testEval(tt.input).(*object.Error)
+		if !ok {
+			t.Errorf("object is not Error for %q", tt.input)
+			continue
+		}
+		assert.Equal(t, tt.expected, errObj.Message)
+	}
+}
+
+func TestDivisionModes(t *testing.T) {
+	// default mode: integer division
+	testIntegerObject(t, testEval("7 / 2"), 3)
+	testIntegerObject(t, testEval("div(7, 2)"), 3)
+
+	TrueDivision = true
+	defer func() { TrueDivision = false }()
+
+	testFloatObject(t, testEval("7 / 2"), 3.5)
+	testIntegerObject(t, testEval("8 / 2"), 4) // exact, stays an integer
+	testIntegerObject(t, testEval("div(7, 2)"), 3)
+	testFloatObject(t, testEval("7 / 2 + 1"), 4.5)
+
+	errObj, ok := testEval("div(7, 0)").(*object.Error)
+	assert.True(t, ok)
+	assert.Equal(t, "division by zero", errObj.Message)
+}
+
+func TestInput(t *testing.T) {
+	var out bytes.Buffer
+	Stdin = bufio.NewReader(strings.NewReader("donald\r\nduck\nlast"))
+	Stdout = &out
+	defer func() {
+		Stdin = bufio.NewReader(os.Stdin)
+		Stdout = os.Stdout
+	}()
+
+	testStringObject(t, testEval(`input("name? ")`), "donald")
+	testStringObject(t, testEval(`input()`), "duck")
+	testStringObject(t, testEval(`input()`), "last") // no trailing newline
+	testNullObject(t, testEval(`input()`))           // EOF
+	assert.Equal(t, "name? ", out.String())
+}
+
+func TestAssert(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{`assert(true)`, nil},
+		{`assert(1 + 1 == 2, "math works")`, nil},
+		{`assert([])`, nil}, // truthy
+		{`assert(false)`, "assertion failed"},
+		{`assert(1 > 2, "1 is not > 2")`, "assertion failed: 1 is not > 2"},
+		{`assert(last([]), 42)`, "assertion failed: 42"},
+		{`assert(false); 5`, "assertion failed"}, // it stops the program
+		{`let f = fn(x) { assert(x > 0, "positive"); x }; f(1) + f(-1)`, "assertion failed: positive"},
+		{`for x in [1, 2] { assert(false, "nope") }; 5`, "assertion failed: nope"},
+		{`let i = 0; while (i < 3) { i = i + 1; assert(i < 2, "i < 2") }`, "assertion failed: i < 2"},
+		{`assert()`, "wrong number of arguments. got=0, want=1 or 2"},
+	}
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		switch expected := tt.expected.(type) {
+		case string:
+			testErrorObject(t, evaluated, expected)
+		default:
+			testNullObject(t, evaluated)
+		}
+	}
+}
+
+func TestInfixEvaluationOrder(t *testing.T) {
+	var out bytes.Buffer
+	Stdout = &out
+	defer func() { Stdout = os.Stdout }()
+
+	input := `let show = fn(x) { puts(x); x }; show(1) + show(2) * show(3)`
+	testIntegerObject(t, testEval(input), 7)
+	assert.Equal(t, "1\n2\n3\n", out.String())
+
+	// the first error is the left one, and the right side is never evaluated
+	out.Reset()
+	input = `let show = fn(x) { puts(x); x }; error("left") + show(2)`
+	testErrorObject(t, testEval(input), "left")
+	assert.Empty(t, out.String())
+	testErrorObject(t, testEval(`left + right`), "identifier not found: left")
+
+	// assignments happen in order too
+	testIntegerObject(t, testEval(`let x = 1; (x = 10) - x`), 0)
+}
+
+func TestEach(t *testing.T) {
+	var out bytes.Buffer
+	Stdout = &out
+	defer func() { Stdout = os.Stdout }()
+
+	testNullObject(t, testEval(`each(fn(x) { puts(x * 2) }, [1, 2, 3])`))
+	assert.Equal(t, "2\n4\n6\n", out.String())
+
+	out.Reset()
+	testNullObject(t, testEval(`each(puts, [])`))
+	testNullObject(t, testEval(`each(puts, ["a", "b"])`))
+	assert.Equal(t, "a\nb\n", out.String())
+
+	// an error stops the iteration
+	out.Reset()
+	input := `each(fn(x) { if (x == 2) { error("two") } puts(x) }, [1, 2, 3])`
+	testErrorObject(t, testEval(input), "two")
+	assert.Equal(t, "1\n", out.String())
+
+	testErrorObject(t, testEval(`each(puts, "abc")`), "second argument to `each` must be ARRAY, got STRING")
+}
+
+func TestExit(t *testing.T) {
+	var out bytes.Buffer
+	Stdout = &out
+	defer func() { Stdout = os.Stdout }()
+
+	tests := []struct {
+		input    string
+		expected int
+	}{
+		{`exit()`, 0},
+		{`exit(2)`, 2},
+		{`puts("a"); exit(1); puts("b")`, 1},
+		// from anywhere: nested blocks and functions, operators, builtins...
+		{`let f = fn() { if (true) { exit(3) }; 1 }; f() + 1`, 3},
+		{`let x = exit(4); x`, 4},
+		{`for i in [1, 2, 3] { if (i == 2) { exit(i) } puts(i) }`, 2},
+		{`let i = 0; while (true) { i = i + 1; if (i == 3) { exit(i) } }`, 3},
+		{`do { exit(8) } while (true)`, 8},
+		{`each(fn(x) { exit(5) }, [1, 2])`, 5},
+		{`[1, exit(6), 3]`, 6},
+		// try only catches errors
+		{`try { exit(7) } catch (e) { 0 }`, 7},
+	}
+	for _, tt := range tests {
+		out.Reset()
+		evaluated := testEval(tt.input)
+		exit, ok := evaluated.(*object.Exit)
+		if !ok {
+			t.Errorf("object is not Exit. got=%T (%+v) for %s", evaluated, evaluated, tt.input)
+			continue
+		}
+		assert.Equal(t, tt.expected, exit.Code, tt.input)
+		assert.NotContains(t, out.String(), "b")
+	}
+	testErrorObject(t, testEval(`exit("1")`), "argument to `exit` must be INTEGER, got STRING")
+	testErrorObject(t, testEval(`exit(1, 2)`), "wrong number of arguments. got=2, want=0 or 1")
+}
+
+func TestErrorBuiltin(t *testing.T) {
+	var out bytes.Buffer
+	Stdout = &out
+	defer func() { Stdout = os.Stdout }()
+
+	testErrorObject(t, testEval(`error("boom")`), "boom")
+	// it short-circuits what follows
+	testErrorObject(t, testEval(`puts("before"); error("boom"); puts("after"); 5`), "boom")
+	assert.Equal(t, "before\n", out.String())
+	input := `
+	let check = fn(x) { if (x < 0) { error("negative: " + str(x)) } x };
+	let total = check(1) + check(-2) + check(3);
+	total`
+	testErrorObject(t, testEval(input), "negative: -2")
+
+	// loops stop too
+	for _, input := range []string{
+		`let i = 0; while (i < 3) { i = i + 1; puts(i); error("boom") }; puts("after")`,
+		`let i = 0; do { i = i + 1; puts(i); error("boom") } while (i < 3); puts("after")`,
+		`for i in [1, 2, 3] { puts(i); error("boom") }; puts("after")`,
+	} {
+		out.Reset()
+		testErrorObject(t, testEval(input), "boom")
+		assert.Equal(t, "1\n", out.String(), input)
+	}
+	testErrorObject(t, testEval(`error(42)`), "argument to `error` must be STRING, got INTEGER")
+}
+
+func TestTryCatch(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		// caught: the fallback value
+		{`try { 5 / 0 } catch (e) { -1 }`, -1},
+		{`let safeDiv = fn(a, b) { try { a / b } catch (e) { 0 } }; safeDiv(6, 3) + safeDiv(1, 0)`, 2},
+		{`try { 5 / 0 } catch (e) { e }`, "division by zero"},
+		{`try { error("boom") } catch (e) { "caught " + e }`, "caught boom"},
+		{`try { let f = fn() { 1 + true }; f() } catch (e) { e }`, "type mismatch: INTEGER + BOOLEAN"},
+		{`try { for x in [1] { 1 / 0 } } catch (e) { e }`, "division by zero"},
+		{`let i = 0; try { while (true) { i = i + 1; if (i == 3) { error("at " + str(i)) } } } catch (e) { e }`, "at 3"},
+		// no error: the value of the body
+		{`try { 5 / 1 } catch (e) { -1 }`, 5},
+		{`let x = 1; try { x = 2 } catch (e) { x = 3 }; x`, 2},
+		// what follows the error isn't evaluated
+		{`let x = 1; try { error("stop"); x = 2 } catch (e) { 0 }; x`, 1},
+		// the error is the first one
+		{`try { error("a"); error("b") } catch (e) { e }`, "a"},
+		// e is only bound in the catch block
+		{`try { error("a") } catch (e) { 0 }; e`, "identifier not found: e"},
+		// an error in the catch block goes up
+		{`try { error("a") } catch (e) { error("again: " + e) }`, "again: a"},
+		{`try { try { error("in") } catch (e) { error(e + " out") } } catch (e) { e }`, "in out"},
+		// returns go through
+		{`let f = fn() { try { return 1; 2 } catch (e) { 3 }; 4 }; f()`, 1},
+	}
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case string:
+			if errObj, ok := evaluated.(*object.Error); ok {
+				assert.Equal(t, expected, errObj.Message, tt.input)
+			} else {
+				testStringObject(t, evaluated, expected)
+			}
+		}
+	}
+}
+
+func TestPrint(t *testing.T) {
+	var out bytes.Buffer
+	Stdout = &out
+	defer func() { Stdout = os.Stdout }()
+
+	testNullObject(t, testEval(`print("a"); print("b")`))
+	assert.Equal(t, "ab", out.String())
+
+	out.Reset()
+	testEval(`print("x = ", 1, [2]); puts("y")`)
+	assert.Equal(t, "x = 1[2]y\n", out.String())
+}
+
+func TestDivisionByZero(t *testing.T) {
+	testErrorObject(t, testEval("5 / 0"), "division by zero")
+	testErrorObject(t, testEval("let x = 0; 5 / x"), "division by zero")
+	testErrorObject(t, testEval("0 / 0"), "division by zero")
+	testErrorObject(t, testEval("(-9223372036854775807 - 1) / -1"), "integer overflow")
+	testIntegerObject(t, testEval("0 / 5"), 0)
+
+	TrueDivision = true
+	defer func() { TrueDivision = false }()
+	testErrorObject(t, testEval("5 / 0"), "division by zero")
+}
+
+func TestMod(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"mod(7, 2)", 1},
+		{"mod(8, 2)", 0},
+		{"mod(-7, 2)", 1}, // same sign as the divisor
+		{"mod(7, -2)", -1},
+		{"mod(-7, -2)", -1},
+		{"let a = -7; let b = 2; div(a, b) * b + mod(a, b) == a", true},
+		{"mod(7, 0)", "division by zero"},
+		{"mod(7, 1.5)", "arguments to `mod` must be INTEGER, got INTEGER and FLOAT"},
+	}
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case bool:
+			testBooleanObject(t, evaluated, expected)
+		case string:
+			testErrorObject(t, evaluated, expected)
+		}
+	}
+}
+
+func TestNow(t *testing.T) {
+	Now = func() time.Time { return time.UnixMilli(1700000000123) }
+	defer func() { Now = time.Now }()
+
+	testIntegerObject(t, testEval(`now()`), 1700000000123)
+	testIntegerObject(t, testEval(`let start = now(); now() - start`), 0)
+	testErrorObject(t, testEval(`now(1)`), "wrong number of arguments. got=1, want=0")
+}
+
+func TestFloorDivision(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"7 ~/ 2", 3},
+		{"8 ~/ 2", 4},
+		{"-7 ~/ 2", -4},
+		{"7 ~/ -2", -4},
+		{"-7 ~/ -2", 3},
+		{"-8 ~/ 2", -4},
+		{"1 + 7 ~/ 2 * 2", 7},
+		{"div(-7, 2)", -4},
+		{"7 ~/ 0", "division by zero"},
+		{"div(7, 0)", "division by zero"},
+		{"(-9223372036854775807 - 1) ~/ -1", "integer overflow"},
+		{"div(-9223372036854775807 - 1, -1)", "integer overflow"},
+		{"(-9223372036854775807 - 1) ~/ 1", math.MinInt64},
+	}
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case string:
+			errObj, ok := evaluated.(*object.Error)
+			if !ok {
+				t.Errorf("object is not Error. got=%T (%+v)", evaluated, evaluated)
+				continue
+			}
+			assert.Equal(t, expected, errObj.Message)
+		}
+	}
+}
+
+func TestUnicodeIdentifiers(t *testing.T) {
+	testFloatObject(t, testEval(`let π = 3.14; let r = 2.0; π * r * r`), 12.56)
+	testStringObject(t, testEval(`let café = "crème"; café + " brûlée"`), "crème brûlée")
+}
+
+func TestClosuresInForLoop(t *testing.T) {
+	input := `
+	let fns = [];
+	for i in [0, 1, 2] {
+		fns = fns + [fn() { i }]
+	};
+	[fns[0](), fns[1](), fns[2]()]`
+	testIntegerArray(t, testEval(input), []int{0, 1, 2})
+
+	// same when looping through an identifier
+	input = `
+	let xs = [10, 20];
+	let fns = [];
+	for x in xs {
+		let double = x * 2;
+		fns = fns + [fn() { x + double }]
+	};
+	[fns[0](), fns[1]()]`
+	testIntegerArray(t, testEval(input), []int{30, 60})
+
+	// the iterator doesn't leak out of the loop
+	testErrorObject(t, testEval(`for i in [1] { i }; i`), "identifier not found: i")
+}
+
 // helpers
 
 func testEval(input string) object.Object {
@@ -598,6 +2481,38 @@ func testStringObject(t *testing.T, obj object.Object, expected string) bool {
 	return true
 }
 
+func testFloatObject(t *testing.T, obj object.Object, expected float64) bool {
+	result, ok := obj.(*object.Float)
+	if !ok {
+		t.Errorf("object is not Float. got=%T (%+v)", obj, obj)
+		return false
+	}
+	if result.Value != expected {
+		t.Errorf("object has wrong value. got=%g, want=%g",
+			result.Value, expected)
+		return false
+	}
+	return true
+}
+
+func testIntegerArray(t *testing.T, obj object.Object, expected []int) bool {
+	array, ok := obj.(*object.Array)
+	if !ok {
+		t.Errorf("object is not Array. got=%T (%+v)", obj, obj)
+		return false
+	}
+	if len(array.Elements) != len(expected) {
+		t.Errorf("wrong number of elements. got=%d, want=%d", len(array.Elements), len(expected))
+		return false
+	}
+	for i, ex := range expected {
+		if !testIntegerObject(t, array.Elements[i], int64(ex)) {
+			return false
+		}
+	}
+	return true
+}
+
 func testBooleanObject(t *testing.T, obj object.Object, expected bool) bool {
 	result, ok := obj.(*object.Boolean)
 	if !ok {
@@ -612,6 +2527,19 @@ func testBooleanObject(t *testing.T, obj object.Object, expected bool) bool {
 	return true
 }
 
+func testErrorObject(t *testing.T, obj object.Object, expected string) bool {
+	errObj, ok := obj.(*object.Error)
+	if !ok {
+		t.Errorf("object is not Error. got=%T (%+v)", obj, obj)
+		return false
+	}
+	if errObj.Message != expected {
+		t.Errorf("wrong error message. expected=%q, got=%q", expected, errObj.Message)
+		return false
+	}
+	return true
+}
+
 func testNullObject(t *testing.T, obj object.Object) bool {
 	if obj != NULL {
 		t.Errorf("object is not NULL. got=%T (%+v)", obj, obj)
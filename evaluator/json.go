@@ -0,0 +1,142 @@
+package evaluator
+
+import (
+	"encoding/json"
+	"io"
+	"math"
+	"monkey/object"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// toJSON renders obj as (compact) JSON; the keys of hashmaps are sorted,
+// and the ones that aren't strings are quoted: {1: true} is {"1":true}
+func toJSON(obj object.Object) (string, *object.Error) {
+	var out strings.Builder
+	if err := writeJSON(&out, obj); err != nil {
+		return "", err
+	}
+	return out.String(), nil
+}
+
+func writeJSON(out *strings.Builder, obj object.Object) *object.Error {
+	switch obj := obj.(type) {
+	case *object.Null:
+		out.WriteString("null")
+	case *object.Boolean:
+		out.WriteString(strconv.FormatBool(obj.Value))
+	case *object.Integer:
+		out.WriteString(strconv.FormatInt(obj.Value, 10))
+	case *object.Float:
+		if math.IsInf(obj.Value, 0) || math.IsNaN(obj.Value) {
+			return newError("cannot convert %s to JSON", obj.Inspect())
+		}
+		out.WriteString(strconv.FormatFloat(obj.Value, 'g', -1, 64))
+	case *object.String:
+		writeJSONString(out, obj.Value)
+	case *object.Array:
+		out.WriteString("[")
+		for i, el := range obj.Elements {
+			if i > 0 {
+				out.WriteString(",")
+			}
+			if err := writeJSON(out, el); err != nil {
+				return err
+			}
+		}
+		out.WriteString("]")
+	case *object.HashMap:
+		values := map[string]object.Object{}
+		keys := []string{}
+		for _, pair := range obj.Pairs {
+			k := object.ToString(pair.Key)
+			if _, ok := values[k]; ok { // 1 and "1" would be the same key
+				return newError("cannot convert to JSON: duplicate key %q", k)
+			}
+			values[k] = pair.Value
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+
+		out.WriteString("{")
+		for i, k := range keys {
+			if i > 0 {
+				out.WriteString(",")
+			}
+			writeJSONString(out, k)
+			out.WriteString(":")
+			if err := writeJSON(out, values[k]); err != nil {
+				return err
+			}
+		}
+		out.WriteString("}")
+	default:
+		return newError("cannot convert %s to JSON", obj.Type())
+	}
+	return nil
+}
+
+func writeJSONString(out *strings.Builder, s string) {
+	quoted, _ := json.Marshal(s) // can't fail on a string
+	out.Write(quoted)
+}
+
+// fromJSON is the opposite of toJSON: numbers become integers when they're
+// whole (1, 1.0 and 1e3 are all integers), floats otherwise
+func fromJSON(s string) object.Object {
+	dec := json.NewDecoder(strings.NewReader(s))
+	dec.UseNumber()
+	var value interface{}
+	if err := dec.Decode(&value); err != nil {
+		return newError("cannot parse JSON: %s", err)
+	}
+	if _, err := dec.Token(); err != io.EOF {
+		return newError("cannot parse JSON: unexpected data after the value")
+	}
+	return jsonToObject(value)
+}
+
+func jsonToObject(value interface{}) object.Object {
+	switch value := value.(type) {
+	case nil:
+		return NULL
+	case bool:
+		return nativeBoolToBooleanObject(value)
+	case json.Number:
+		if i, err := value.Int64(); err == nil {
+			return newInteger(i)
+		}
+		f, err := value.Float64()
+		if err != nil {
+			return newError("cannot parse JSON: number out of range %s", value)
+		}
+		if f == math.Trunc(f) && math.Abs(f) < math.MaxInt64 {
+			return newInteger(int64(f))
+		}
+		return &object.Float{Value: f}
+	case string:
+		return &object.String{Value: value}
+	case []interface{}:
+		elements := make([]object.Object, len(value))
+		for i, el := range value {
+			elements[i] = jsonToObject(el)
+			if isError(elements[i]) {
+				return elements[i]
+			}
+		}
+		return &object.Array{Elements: elements}
+	case map[string]interface{}:
+		pairs := make(map[object.HashKey]object.HashPair, len(value))
+		for k, v := range value {
+			key := &object.String{Value: k}
+			val := jsonToObject(v)
+			if isError(val) {
+				return val
+			}
+			pairs[key.HashKey()] = object.HashPair{Key: key, Value: val}
+		}
+		return &object.HashMap{Pairs: pairs}
+	}
+	return newError("cannot parse JSON: unexpected %T", value)
+}
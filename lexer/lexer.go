@@ -2,48 +2,74 @@ package lexer
 
 import (
 	"monkey/token"
+	"unicode"
+	"unicode/utf8"
 )
 
+// the lexer works on runes, so that identifiers like `café` or `π` work;
+// positions are still byte offsets in the input
 type Lexer struct {
 	input        string
-	position     int  // points to the ch byte
-	readPosition int  // points to the next char in input
-	ch           byte // current char
+	position     int  // points to the ch rune
+	readPosition int  // points to the next rune in input
+	ch           rune // current char
+	line         int  // line of the current char
 }
 
 func New(input string) *Lexer {
-	l := &Lexer{input: input}
+	return NewAt(input, 1)
+}
+
+// NewAt is like New, for input that doesn't start on the first line, e.g.
+// the expressions embedded in a string: lines are counted from line
+func NewAt(input string, line int) *Lexer {
+	l := &Lexer{input: input, line: line}
 	l.readChar() // init the lexer
 	return l
 }
 
 // set l.ch to next char, and advance our position in the input
 func (l *Lexer) readChar() {
+	if l.ch == '\n' { // moving past a newline
+		l.line += 1
+	}
 	// EOF, set ch to 0 (ASCII `NUL`)
+	width := 1
 	if l.readPosition >= len(l.input) {
 		l.ch = 0
 	} else {
-		l.ch = l.input[l.readPosition]
+		l.ch, width = utf8.DecodeRuneInString(l.input[l.readPosition:])
 	}
 
 	l.position = l.readPosition
-	l.readPosition += 1
+	l.readPosition += width
 }
 
 // returns the next char to scan; immutable
-func (l *Lexer) peekChar() byte {
+func (l *Lexer) peekChar() rune {
+	return l.peekCharAt(0)
+}
+
+// returns the char n positions after the next one; immutable
+func (l *Lexer) peekCharAt(n int) rune {
+	pos := l.readPosition
+	for ; n > 0 && pos < len(l.input); n-- {
+		_, width := utf8.DecodeRuneInString(l.input[pos:])
+		pos += width
+	}
 	// EOF
-	if l.readPosition >= len(l.input) {
+	if pos >= len(l.input) {
 		return 0
-	} else {
-		return l.input[l.readPosition]
 	}
+	r, _ := utf8.DecodeRuneInString(l.input[pos:])
+	return r
 }
 
 func (l *Lexer) NextToken() token.Token {
 	var tok token.Token
 
 	l.skipWhitespace()
+	line := l.line
 	switch l.ch {
 	case '=':
 		if l.peekChar() == '=' {
@@ -60,6 +86,8 @@ func (l *Lexer) NextToken() token.Token {
 		tok = newToken(token.LPAREN, l.ch)
 	case ')':
 		tok = newToken(token.RPAREN, l.ch)
+	case '.':
+		tok = newToken(token.DOT, l.ch)
 	case ',':
 		tok = newToken(token.COMMA, l.ch)
 	case '+':
@@ -75,6 +103,20 @@ func (l *Lexer) NextToken() token.Token {
 		} else {
 			tok = newToken(token.BANG, l.ch)
 		}
+	case '&':
+		if l.peekChar() == '&' {
+			l.readChar() // read next char, &, and move on
+			tok = token.Token{Type: token.AND, Literal: "&&"}
+		} else {
+			tok = newToken(token.ILLEGAL, l.ch)
+		}
+	case '|':
+		if l.peekChar() == '|' {
+			l.readChar() // read next char, |, and move on
+			tok = token.Token{Type: token.OR, Literal: "||"}
+		} else {
+			tok = newToken(token.ILLEGAL, l.ch)
+		}
 	case '-':
 		tok = newToken(token.MINUS, l.ch)
 	case '/':
@@ -84,15 +126,39 @@ func (l *Lexer) NextToken() token.Token {
 		} else {
 			tok = newToken(token.SLASH, l.ch)
 		}
+	case '~':
+		if l.peekChar() == '/' {
+			l.readChar() // read next char, /, and move on
+			tok = token.Token{Type: token.INT_DIV, Literal: "~/"}
+		} else {
+			tok = newToken(token.TILDE, l.ch)
+		}
 	case '*':
 		tok = newToken(token.ASTERISK, l.ch)
 	case '<':
-		tok = newToken(token.LT, l.ch)
+		if l.peekChar() == '=' {
+			l.readChar() // read next char, =, and move on
+			tok = token.Token{Type: token.LT_EQ, Literal: "<="}
+		} else {
+			tok = newToken(token.LT, l.ch)
+		}
 	case '>':
-		tok = newToken(token.GT, l.ch)
+		if l.peekChar() == '=' {
+			l.readChar() // read next char, =, and move on
+			tok = token.Token{Type: token.GT_EQ, Literal: ">="}
+		} else {
+			tok = newToken(token.GT, l.ch)
+		}
 	case '"':
-		tok.Type = token.STRING
-		tok.Literal = l.readString()
+		literal, ok := l.readString()
+		if ok {
+			tok.Type = token.STRING
+			tok.Literal = literal
+		} else {
+			// the whole rest of the input, opening quote included
+			tok.Type = token.ILLEGAL
+			tok.Literal = `"` + literal
+		}
 	case '[':
 		tok = newToken(token.LBRACKET, l.ch)
 	case ']':
@@ -105,11 +171,12 @@ func (l *Lexer) NextToken() token.Token {
 		if isLetter(l.ch) {
 			tok.Literal = l.readIdentifier()
 			tok.Type = token.LookupIdent(tok.Literal)
+			tok.Line = line
 			return tok // so we don't call readChar again at the end
 		}
 		if isNumber(l.ch) {
-			tok.Literal = l.readNumber()
-			tok.Type = token.INT
+			tok.Literal, tok.Type = l.readNumber()
+			tok.Line = line
 			return tok // so we don't call readChar again at the end
 		} else {
 			tok = newToken(token.ILLEGAL, l.ch)
@@ -118,37 +185,122 @@ func (l *Lexer) NextToken() token.Token {
 
 	l.readChar() // set up for next char
 
+	tok.Line = line
 	return tok
 }
 
+// Tokens drains the lexer: every token left, including the final EOF
+func (l *Lexer) Tokens() []token.Token {
+	tokens := []token.Token{}
+	for {
+		tok := l.NextToken()
+		tokens = append(tokens, tok)
+		if tok.Type == token.EOF {
+			return tokens
+		}
+	}
+}
+
 // read a whole identifier (keywords or variable names)
+// the first char is a letter, NextToken checks it; digits can only come after it
 func (l *Lexer) readIdentifier() string {
 	initPosition := l.position
-	for isLetter(l.ch) {
+	for isLetter(l.ch) || isNumber(l.ch) {
 		l.readChar()
 	}
 	return l.input[initPosition:l.position]
 }
 
-// read a whole number
-func (l *Lexer) readNumber() string {
+// read a whole number, either an INT or a FLOAT: 1.5, 1.5e3, 2E-2 and 1e3
+// are all floats. It can contain underscores, as in 1_000_000
+// (the parser checks they're only between digits)
+func (l *Lexer) readNumber() (string, token.TokenType) {
 	initPosition := l.position
-	for isNumber(l.ch) {
+	var tokenType token.TokenType = token.INT
+	l.readDigits()
+
+	// fractional part: only if there's a digit after the dot
+	if l.ch == '.' && isNumber(l.peekChar()) {
+		tokenType = token.FLOAT
+		l.readChar() // move past .
+		l.readDigits()
+	}
+
+	// exponent, with an optional sign: e3, E-2, e+10
+	if l.ch == 'e' || l.ch == 'E' {
+		if isNumber(l.peekChar()) ||
+			(l.peekChar() == '+' || l.peekChar() == '-') && isNumber(l.peekCharAt(1)) {
+			tokenType = token.FLOAT
+			l.readChar() // move past e
+			if l.ch == '+' || l.ch == '-' {
+				l.readChar()
+			}
+			l.readDigits()
+		}
+	}
+	return l.input[initPosition:l.position], tokenType
+}
+
+func (l *Lexer) readDigits() {
+	for isNumber(l.ch) || l.ch == '_' {
 		l.readChar()
 	}
-	return l.input[initPosition:l.position]
 }
 
-// read a whole string
-func (l *Lexer) readString() string {
+// read a string up to its closing quote; false if the input ends before it
+func (l *Lexer) readString() (string, bool) {
 	position := l.position + 1 // skip first quote
+	ok := l.skipString()
+	return l.input[position:l.position], ok
+}
+
+// skipString moves to the quote closing the string opened at l.ch. The
+// quotes in a ${...} are the ones of the strings in the expression, as in
+// "${h["k"]}": they don't close this one
+func (l *Lexer) skipString() bool {
 	for {
 		l.readChar()
-		if l.ch == '"' || l.ch == 0 {
-			break
+		switch {
+		case l.ch == '"':
+			return true
+		case l.ch == 0:
+			return false
+		case l.ch == '\\' && l.peekChar() == '$':
+			l.readChar() // `\${` is just text
+		case l.ch == '$' && l.peekChar() == '{':
+			saved := *l
+			l.readChar()
+			if !l.skipEmbedded() {
+				// no closing }: that's for the parser to report, the string
+				// ends at the next quote
+				*l = saved
+			}
+		}
+	}
+}
+
+// skipEmbedded moves to the } closing the ${ at l.ch, skipping nested
+// braces and strings; false if there's none
+func (l *Lexer) skipEmbedded() bool {
+	depth := 0
+	for {
+		l.readChar()
+		switch l.ch {
+		case 0:
+			return false
+		case '"':
+			if !l.skipString() {
+				return false
+			}
+		case '{':
+			depth++
+		case '}':
+			if depth == 0 {
+				return true
+			}
+			depth--
 		}
 	}
-	return l.input[position:l.position]
 }
 
 // read a whole comment
@@ -168,17 +320,19 @@ func (l *Lexer) skipWhitespace() {
 	}
 }
 
-func newToken(tokenType token.TokenType, ch byte) token.Token {
+func newToken(tokenType token.TokenType, ch rune) token.Token {
 	return token.Token{
 		Type:    tokenType,
 		Literal: string(ch),
 	}
 }
 
-func isLetter(ch byte) bool {
-	return 'a' <= ch && ch <= 'z' || 'A' <= ch && ch <= 'Z' || ch == '_'
+// any unicode letter: `café` and `π` are identifiers
+func isLetter(ch rune) bool {
+	return unicode.IsLetter(ch) || ch == '_'
 }
 
-func isNumber(ch byte) bool {
+// only ASCII digits, the ones strconv can parse
+func isNumber(ch rune) bool {
 	return '0' <= ch && ch <= '9'
 }
@@ -168,3 +168,355 @@ func TestNextToken(t *testing.T) {
 		assert.Equal(t, tt.expectedLiteral, tok.Literal)
 	}
 }
+
+func TestIntegerDivision(t *testing.T) {
+	input := `7 ~/ 2 ~ /~5 // comment`
+
+	tests := []struct {
+		expectedType    token.TokenType
+		expectedLiteral string
+	}{
+		{token.INT, "7"},
+		{token.INT_DIV, "~/"},
+		{token.INT, "2"},
+		{token.TILDE, "~"},
+		{token.SLASH, "/"},
+		{token.TILDE, "~"},
+		{token.INT, "5"},
+		{token.COMMENT, "#"},
+		{token.EOF, ""},
+	}
+
+	l := New(input)
+
+	for _, tt := range tests {
+		tok := l.NextToken()
+		assert.Equal(t, tt.expectedType, tok.Type)
+		assert.Equal(t, tt.expectedLiteral, tok.Literal)
+	}
+}
+
+func TestDot(t *testing.T) {
+	input := `"abc".len() 1.5.x`
+
+	tests := []struct {
+		expectedType    token.TokenType
+		expectedLiteral string
+	}{
+		{token.STRING, "abc"},
+		{token.DOT, "."},
+		{token.IDENT, "len"},
+		{token.LPAREN, "("},
+		{token.RPAREN, ")"},
+		{token.FLOAT, "1.5"},
+		{token.DOT, "."},
+		{token.IDENT, "x"},
+		{token.EOF, ""},
+	}
+
+	l := New(input)
+
+	for _, tt := range tests {
+		tok := l.NextToken()
+		assert.Equal(t, tt.expectedType, tok.Type)
+		assert.Equal(t, tt.expectedLiteral, tok.Literal)
+	}
+}
+
+func TestUnicodeIdentifiers(t *testing.T) {
+	input := `let café = "naïve π"; π + über_x;
+ñ`
+
+	tests := []struct {
+		expectedType    token.TokenType
+		expectedLiteral string
+		expectedLine    int
+	}{
+		{token.LET, "let", 1},
+		{token.IDENT, "café", 1},
+		{token.ASSIGN, "=", 1},
+		{token.STRING, "naïve π", 1},
+		{token.SEMICOLON, ";", 1},
+		{token.IDENT, "π", 1},
+		{token.PLUS, "+", 1},
+		{token.IDENT, "über_x", 1},
+		{token.SEMICOLON, ";", 1},
+		{token.IDENT, "ñ", 2},
+		{token.EOF, "", 2},
+	}
+
+	l := New(input)
+
+	for _, tt := range tests {
+		tok := l.NextToken()
+		assert.Equal(t, tt.expectedType, tok.Type)
+		assert.Equal(t, tt.expectedLiteral, tok.Literal)
+		assert.Equal(t, tt.expectedLine, tok.Line)
+	}
+}
+
+func TestTokens(t *testing.T) {
+	tokens := New("let x = 1;\nx").Tokens()
+
+	expected := []token.Token{
+		{Type: token.LET, Literal: "let", Line: 1},
+		{Type: token.IDENT, Literal: "x", Line: 1},
+		{Type: token.ASSIGN, Literal: "=", Line: 1},
+		{Type: token.INT, Literal: "1", Line: 1},
+		{Type: token.SEMICOLON, Literal: ";", Line: 1},
+		{Type: token.IDENT, Literal: "x", Line: 2},
+		{Type: token.EOF, Literal: "", Line: 2},
+	}
+	assert.Equal(t, expected, tokens)
+
+	assert.Equal(t, []token.Token{{Type: token.EOF, Literal: "", Line: 1}}, New("").Tokens())
+}
+
+func TestLogicalOperators(t *testing.T) {
+	input := `a && b || c & d | e`
+
+	tests := []struct {
+		expectedType    token.TokenType
+		expectedLiteral string
+	}{
+		{token.IDENT, "a"},
+		{token.AND, "&&"},
+		{token.IDENT, "b"},
+		{token.OR, "||"},
+		{token.IDENT, "c"},
+		{token.ILLEGAL, "&"},
+		{token.IDENT, "d"},
+		{token.ILLEGAL, "|"},
+		{token.IDENT, "e"},
+		{token.EOF, ""},
+	}
+
+	l := New(input)
+
+	for _, tt := range tests {
+		tok := l.NextToken()
+		assert.Equal(t, tt.expectedType, tok.Type)
+		assert.Equal(t, tt.expectedLiteral, tok.Literal)
+	}
+}
+
+func TestIdentifiersWithDigits(t *testing.T) {
+	input := `let x2 = 5; x2 a1b2 3c`
+
+	tests := []struct {
+		expectedType    token.TokenType
+		expectedLiteral string
+	}{
+		{token.LET, "let"},
+		{token.IDENT, "x2"},
+		{token.ASSIGN, "="},
+		{token.INT, "5"},
+		{token.SEMICOLON, ";"},
+		{token.IDENT, "x2"},
+		{token.IDENT, "a1b2"},
+		{token.INT, "3"}, // not as the first char
+		{token.IDENT, "c"},
+		{token.EOF, ""},
+	}
+
+	l := New(input)
+
+	for _, tt := range tests {
+		tok := l.NextToken()
+		assert.Equal(t, tt.expectedType, tok.Type)
+		assert.Equal(t, tt.expectedLiteral, tok.Literal)
+	}
+}
+
+func TestIllegalCharacters(t *testing.T) {
+	input := `a @ 1 € $`
+
+	tests := []struct {
+		expectedType    token.TokenType
+		expectedLiteral string
+	}{
+		{token.IDENT, "a"},
+		{token.ILLEGAL, "@"},
+		{token.INT, "1"},
+		{token.ILLEGAL, "€"}, // the whole rune
+		{token.ILLEGAL, "$"},
+		{token.EOF, ""},
+	}
+
+	l := New(input)
+
+	for _, tt := range tests {
+		tok := l.NextToken()
+		assert.Equal(t, tt.expectedType, tok.Type)
+		assert.Equal(t, tt.expectedLiteral, tok.Literal)
+	}
+}
+
+func TestUnterminatedString(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected []token.Token
+	}{
+		{`"hello`, []token.Token{{Type: token.ILLEGAL, Literal: `"hello`, Line: 1}, {Type: token.EOF, Line: 1}}},
+		{`"`, []token.Token{{Type: token.ILLEGAL, Literal: `"`, Line: 1}, {Type: token.EOF, Line: 1}}},
+		{"x = \"a\nb", []token.Token{
+			{Type: token.IDENT, Literal: "x", Line: 1},
+			{Type: token.ASSIGN, Literal: "=", Line: 1},
+			{Type: token.ILLEGAL, Literal: "\"a\nb", Line: 1},
+			{Type: token.EOF, Line: 2},
+		}},
+		// terminated ones are fine
+		{`"a" "`, []token.Token{{Type: token.STRING, Literal: "a", Line: 1}, {Type: token.ILLEGAL, Literal: `"`, Line: 1}, {Type: token.EOF, Line: 1}}},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.expected, New(tt.input).Tokens(), tt.input)
+	}
+}
+
+func TestInterpolatedStringQuotes(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		// the quotes in a ${...} don't end the string
+		{`"${h["k"]}"`, `${h["k"]}`},
+		{`"a ${f("}", "${x}")} b"`, `a ${f("}", "${x}")} b`},
+		// ...unless there's no closing }
+		{`"a ${b" + "c"`, `a ${b`},
+		{`"a \${"`, `a \${`},
+		{`"${"a}"`, `${`},
+	}
+	for _, tt := range tests {
+		tok := New(tt.input).NextToken()
+		assert.Equal(t, token.Token{Type: token.STRING, Literal: tt.expected, Line: 1}, tok, tt.input)
+	}
+}
+
+func TestNewAt(t *testing.T) {
+	l := NewAt("a\nb", 5)
+	assert.Equal(t, token.Token{Type: token.IDENT, Literal: "a", Line: 5}, l.NextToken())
+	assert.Equal(t, token.Token{Type: token.IDENT, Literal: "b", Line: 6}, l.NextToken())
+}
+
+func TestComparisonOperators(t *testing.T) {
+	input := `1 <= 2 >= 3 < 4 > 5`
+
+	tests := []struct {
+		expectedType    token.TokenType
+		expectedLiteral string
+	}{
+		{token.INT, "1"},
+		{token.LT_EQ, "<="},
+		{token.INT, "2"},
+		{token.GT_EQ, ">="},
+		{token.INT, "3"},
+		{token.LT, "<"},
+		{token.INT, "4"},
+		{token.GT, ">"},
+		{token.INT, "5"},
+		{token.EOF, ""},
+	}
+
+	l := New(input)
+
+	for _, tt := range tests {
+		tok := l.NextToken()
+		assert.Equal(t, tt.expectedType, tok.Type)
+		assert.Equal(t, tt.expectedLiteral, tok.Literal)
+	}
+}
+
+func TestNumbersWithUnderscores(t *testing.T) {
+	// the lexer reads them as they are, the parser validates them
+	input := `1_000_000 1__0 2_ _5`
+
+	tests := []struct {
+		expectedType    token.TokenType
+		expectedLiteral string
+	}{
+		{token.INT, "1_000_000"},
+		{token.INT, "1__0"},
+		{token.INT, "2_"},
+		{token.IDENT, "_5"}, // a leading underscore starts an identifier
+		{token.EOF, ""},
+	}
+
+	l := New(input)
+
+	for _, tt := range tests {
+		tok := l.NextToken()
+		assert.Equal(t, tt.expectedType, tok.Type)
+		assert.Equal(t, tt.expectedLiteral, tok.Literal)
+	}
+}
+
+func TestFloats(t *testing.T) {
+	input := `1.5 0.25 1.5e3 2E-2 1e3 3.0e+2 1_000.5 7 1.x 2e 3e+`
+
+	tests := []struct {
+		expectedType    token.TokenType
+		expectedLiteral string
+	}{
+		{token.FLOAT, "1.5"},
+		{token.FLOAT, "0.25"},
+		{token.FLOAT, "1.5e3"},
+		{token.FLOAT, "2E-2"},
+		{token.FLOAT, "1e3"},
+		{token.FLOAT, "3.0e+2"},
+		{token.FLOAT, "1_000.5"},
+		{token.INT, "7"},
+		// no digit after the dot or the exponent: not part of the number
+		{token.INT, "1"},
+		{token.DOT, "."},
+		{token.IDENT, "x"},
+		{token.INT, "2"},
+		{token.IDENT, "e"},
+		{token.INT, "3"},
+		{token.IDENT, "e"},
+		{token.PLUS, "+"},
+		{token.EOF, ""},
+	}
+
+	l := New(input)
+
+	for _, tt := range tests {
+		tok := l.NextToken()
+		assert.Equal(t, tt.expectedType, tok.Type)
+		assert.Equal(t, tt.expectedLiteral, tok.Literal)
+	}
+}
+
+func TestLineNumbers(t *testing.T) {
+	input := `let x = 5;
+let s = "multi
+line";
+// a comment
+x`
+
+	tests := []struct {
+		expectedType token.TokenType
+		expectedLine int
+	}{
+		{token.LET, 1},
+		{token.IDENT, 1},
+		{token.ASSIGN, 1},
+		{token.INT, 1},
+		{token.SEMICOLON, 1},
+		{token.LET, 2},
+		{token.IDENT, 2},
+		{token.ASSIGN, 2},
+		{token.STRING, 2},
+		{token.SEMICOLON, 3},
+		{token.COMMENT, 4},
+		{token.IDENT, 5},
+		{token.EOF, 5},
+	}
+
+	l := New(input)
+
+	for _, tt := range tests {
+		tok := l.NextToken()
+		assert.Equal(t, tt.expectedType, tok.Type)
+		assert.Equal(t, tt.expectedLine, tok.Line)
+	}
+}
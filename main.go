@@ -1,46 +1,163 @@
 package main
 
 import (
+	"bufio"
+	"flag"
 	"fmt"
+	"io"
+	"monkey/ast"
+	"monkey/compiler"
 	"monkey/evaluator"
 	"monkey/lexer"
 	"monkey/object"
 	"monkey/parser"
 	"monkey/repl"
+	"monkey/token"
+	"monkey/vm"
 	"os"
 	"os/user"
 )
 
 func main() {
+	os.Exit(run(os.Args, os.Stdin, os.Stdout, os.Stderr))
+}
 
-	u, err := user.Current()
-	if err != nil {
-		panic(err)
-	}
+// run is the whole CLI, minus the call to os.Exit: it returns the exit code
+// so that it can be tested
+func run(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	evaluator.Stdout = stdout                // so that `puts` goes to the same place as everything else
+	evaluator.Stdin = bufio.NewReader(stdin) // and `input` reads from stdin; the REPL has its own
 
-	if len(os.Args) == 1 {
-		fmt.Printf("Hello %s !\n", u.Username)
-		repl.Start(os.Stdin, os.Stdout)
+	flags := flag.NewFlagSet(args[0], flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	flags.Usage = func() {
+		fmt.Fprintln(stderr, "usage: monkey [-vm | -tokens | -ast] [-eval code | file]")
+		flags.PrintDefaults()
+	}
+	// `monkey -vm script.monkey` runs the script on the bytecode VM,
+	// which only supports integers, booleans and globals for now
+	var opts options
+	flags.BoolVar(&opts.vm, "vm", false, "run on the bytecode VM (integers, booleans and globals only)")
+	flags.BoolVar(&opts.tokens, "tokens", false, "print the tokens, without parsing")
+	flags.BoolVar(&opts.ast, "ast", false, "print the AST, without evaluating")
+	code := flags.String("eval", "", "run `code` instead of a file, e.g. -eval \"puts(1 + 2)\"")
+	if err := flags.Parse(args[1:]); err != nil {
+		return 2
 	}
 
-	if len(os.Args) == 2 {
-		data, err := os.ReadFile(os.Args[1])
+	switch {
+	case *code != "" && flags.NArg() == 0:
+		return runCode(*code, opts, stdout, stderr)
+	case *code == "" && flags.NArg() == 1:
+		return runFile(flags.Arg(0), opts, stdout, stderr)
+	case *code == "" && flags.NArg() == 0 && !isTerminal(stdin):
+		// `echo "puts(1)" | monkey` runs the piped program, no REPL
+		data, err := io.ReadAll(stdin)
+		if err != nil {
+			fmt.Fprintln(stderr, err)
+			return 1
+		}
+		return runCode(string(data), opts, stdout, stderr)
+	case *code == "" && flags.NArg() == 0:
+		u, err := user.Current()
 		if err != nil {
 			panic(err)
 		}
-		l := lexer.New(string(data))
-		p := parser.New(l)
+		fmt.Fprintf(stdout, "Hello %s !\n", u.Username)
+		return repl.Start(stdin, stdout)
+	default:
+		flags.Usage()
+		return 2
+	}
+}
 
-		program := p.ParseProgram()
-		if len(p.Errors()) != 0 {
-			for _, e := range p.Errors() {
-				fmt.Println("Parse error: ", e)
+// isTerminal tells whether r is an interactive terminal, rather than
+// a pipe, a file or anything that isn't even an *os.File
+func isTerminal(r io.Reader) bool {
+	f, ok := r.(*os.File)
+	if !ok {
+		return false
+	}
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+// options are the flags telling what to do with the code
+type options struct {
+	vm     bool // run it on the VM instead of the evaluator
+	tokens bool // only print its tokens
+	ast    bool // only print its AST
+}
+
+func runFile(path string, opts options, stdout, stderr io.Writer) int {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return 1
+	}
+	return runCode(string(data), opts, stdout, stderr)
+}
+
+// runCode runs a whole program, and prints its value
+func runCode(code string, opts options, stdout, stderr io.Writer) int {
+	l := lexer.New(code)
+	if opts.tokens {
+		// like the REPL's :tokens
+		for _, tok := range l.Tokens() {
+			if tok.Type != token.EOF {
+				fmt.Fprintf(stdout, "%+v\n", tok)
 			}
-			return
 		}
+		return 0
+	}
+	p := parser.New(l)
+
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		for _, e := range p.Errors() {
+			fmt.Fprintln(stderr, "Parse error: ", e)
+		}
+		return 1
+	}
+
+	if opts.ast {
+		fmt.Fprint(stdout, ast.Dump(program))
+		return 0
+	}
+	if opts.vm {
+		return runVM(program, stdout, stderr)
+	}
 
-		env := object.NewEnvironment()
-		_ = evaluator.Eval(program, env)
+	env := object.NewEnvironment()
+	evaluated := evaluator.Eval(program, env)
+	if exit, ok := evaluated.(*object.Exit); ok {
+		return exit.Code
+	}
+	if errObj, ok := evaluated.(*object.Error); ok {
+		fmt.Fprintln(stderr, "Runtime error: "+errObj.Describe())
+		return 1
+	}
+	// like the REPL, print the value of the program; but not if it's null,
+	// which is what statements (and `puts`) evaluate to
+	if evaluated != nil && evaluated != evaluator.NULL {
+		fmt.Fprintln(stdout, evaluated.Inspect())
+	}
+	return 0
+}
+
+func runVM(program *ast.Program, stdout, stderr io.Writer) int {
+	comp := compiler.New()
+	if err := comp.Compile(program); err != nil {
+		fmt.Fprintln(stderr, "Compile error: "+err.Error())
+		return 1
+	}
+	machine := vm.New(comp.Bytecode())
+	if err := machine.Run(); err != nil {
+		fmt.Fprintln(stderr, "Runtime error: "+err.Error())
+		return 1
+	}
+	if last := machine.LastPoppedStackElem(); last != nil {
+		fmt.Fprintln(stdout, last.Inspect())
 	}
-	return
+	return 0
 }
@@ -0,0 +1,253 @@
+package main
+
+import (
+	"bytes"
+	"github.com/stretchr/testify/assert"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRunFileRuntimeError(t *testing.T) {
+	path := writeScript(t, "let x = 5;\nx + true;\nputs(\"unreachable\")")
+	var stdout, stderr bytes.Buffer
+
+	code := run([]string{"monkey", path}, nil, &stdout, &stderr)
+
+	assert.Equal(t, 1, code)
+	assert.Equal(t, "Runtime error: line 2: type mismatch: INTEGER + BOOLEAN\n", stderr.String())
+}
+
+func TestRunFileOk(t *testing.T) {
+	tests := []struct {
+		code     string
+		expected string
+	}{
+		{"let x = 5;\nx + 1;", "6\n"},                    // the final value is printed
+		{"let x = 5;", ""},                               // ...unless it's null
+		{`puts("a"); puts("b")`, "a\nb\n"},               // puts returns null too
+		{`puts("a"); "b"`, "a\n\"b\"\n"},                 // printed as in the REPL
+		{"let f = fn(x) { puts(x) }; f(1); 2", "1\n2\n"}, // in order
+	}
+	for _, tt := range tests {
+		path := writeScript(t, tt.code)
+		var stdout, stderr bytes.Buffer
+
+		code := run([]string{"monkey", path}, nil, &stdout, &stderr)
+
+		assert.Equal(t, 0, code)
+		assert.Equal(t, tt.expected, stdout.String())
+		assert.Empty(t, stderr.String())
+	}
+}
+
+func TestRunFileVM(t *testing.T) {
+	tests := []struct {
+		code     string
+		expected string
+	}{
+		{"let x = 5;\nx * 2 + 1;", "11\n"},
+		{"let x = 5;", ""},
+		{"1 < 2", "true\n"},
+	}
+	for _, tt := range tests {
+		path := writeScript(t, tt.code)
+		var stdout, stderr bytes.Buffer
+
+		code := run([]string{"monkey", "-vm", path}, nil, &stdout, &stderr)
+
+		assert.Equal(t, 0, code)
+		assert.Equal(t, tt.expected, stdout.String())
+		assert.Empty(t, stderr.String())
+	}
+
+	errors := []struct {
+		code     string
+		expected string
+	}{
+		{"1 / 0", "Runtime error: division by zero\n"},
+		{`puts("a")`, "Compile error: cannot compile *ast.CallExpression yet\n"},
+	}
+	for _, tt := range errors {
+		path := writeScript(t, tt.code)
+		var stdout, stderr bytes.Buffer
+
+		code := run([]string{"monkey", "-vm", path}, nil, &stdout, &stderr)
+
+		assert.Equal(t, 1, code)
+		assert.Equal(t, tt.expected, stderr.String())
+	}
+}
+
+func TestRunEval(t *testing.T) {
+	tests := []struct {
+		args     []string
+		code     int
+		expected string
+		errors   string
+	}{
+		{[]string{"--eval", "puts(1 + 2)"}, 0, "3\n", ""},
+		{[]string{"-eval", "let x = 5; x * 2"}, 0, "10\n", ""},
+		{[]string{"-vm", "-eval", "1 + 2"}, 0, "3\n", ""},
+		{[]string{"--eval", "1 + true"}, 1, "", "Runtime error: line 1: type mismatch: INTEGER + BOOLEAN\n"},
+		{[]string{"--eval", "let = 1"}, 1, "", "Parse error:  expected next token to be IDENT, got = instead\n"},
+	}
+	for _, tt := range tests {
+		var stdout, stderr bytes.Buffer
+
+		code := run(append([]string{"monkey"}, tt.args...), nil, &stdout, &stderr)
+
+		assert.Equal(t, tt.code, code, tt.args)
+		assert.Equal(t, tt.expected, stdout.String(), tt.args)
+		assert.Equal(t, tt.errors, stderr.String(), tt.args)
+	}
+}
+
+func TestRunDebugFlags(t *testing.T) {
+	path := writeScript(t, "let x = 1 + 2;\nputs(x)")
+	tests := []struct {
+		flag     string
+		expected string
+	}{
+		{"-tokens", `{Type:LET Literal:let Line:1}
+{Type:IDENT Literal:x Line:1}
+{Type:= Literal:= Line:1}
+{Type:INT Literal:1 Line:1}
+{Type:+ Literal:+ Line:1}
+{Type:INT Literal:2 Line:1}
+{Type:; Literal:; Line:1}
+{Type:IDENT Literal:puts Line:2}
+{Type:( Literal:( Line:2}
+{Type:IDENT Literal:x Line:2}
+{Type:) Literal:) Line:2}
+`},
+		{"--ast", `*ast.Program
+  Statements[0]: *ast.LetStatement
+    Name: *ast.Identifier x
+    Value: *ast.InfixExpression +
+      Left: *ast.IntegerLiteral 1
+      Right: *ast.IntegerLiteral 2
+  Statements[1]: *ast.ExpressionStatement
+    Expression: *ast.CallExpression
+      Function: *ast.Identifier puts
+      Arguments[0]: *ast.Identifier x
+`},
+	}
+	for _, tt := range tests {
+		var stdout, stderr bytes.Buffer
+
+		code := run([]string{"monkey", tt.flag, path}, nil, &stdout, &stderr)
+
+		// the script isn't run: no "3"
+		assert.Equal(t, 0, code, tt.flag)
+		assert.Equal(t, tt.expected, stdout.String(), tt.flag)
+		assert.Empty(t, stderr.String(), tt.flag)
+	}
+
+	// the AST needs a program that parses; the tokens don't
+	var stdout, stderr bytes.Buffer
+	assert.Equal(t, 1, run([]string{"monkey", "-ast", "-eval", "let = 1"}, nil, &stdout, &stderr))
+	assert.Equal(t, "Parse error:  expected next token to be IDENT, got = instead\n", stderr.String())
+	stdout.Reset()
+	assert.Equal(t, 0, run([]string{"monkey", "-tokens", "-eval", "let = @"}, nil, &stdout, &stderr))
+	assert.Contains(t, stdout.String(), "{Type:ILLEGAL Literal:@ Line:1}")
+}
+
+func TestRunStdin(t *testing.T) {
+	var stdout, stderr bytes.Buffer
+
+	code := run([]string{"monkey"}, strings.NewReader("puts(1 + 2)\nlet x = 4;\nx * 2"), &stdout, &stderr)
+
+	// no banner, and no prompt
+	assert.Equal(t, 0, code)
+	assert.Equal(t, "3\n8\n", stdout.String())
+	assert.Empty(t, stderr.String())
+
+	// flags apply to it too
+	stdout.Reset()
+	code = run([]string{"monkey", "-vm"}, strings.NewReader("1 + true"), &stdout, &stderr)
+	assert.Equal(t, 1, code)
+	assert.Equal(t, "Runtime error: type mismatch: INTEGER + BOOLEAN\n", stderr.String())
+
+	// a file piped in is not a terminal either
+	path := writeScript(t, "5 * 5")
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	stdout.Reset()
+	assert.False(t, isTerminal(f))
+	assert.Equal(t, 0, run([]string{"monkey"}, f, &stdout, &stderr))
+	assert.Equal(t, "25\n", stdout.String())
+}
+
+func TestRunInput(t *testing.T) {
+	var stdout, stderr bytes.Buffer
+
+	// input() reads from the stdin given to run
+	code := run([]string{"monkey", "-eval", `puts(input("? ") + "!"); puts(input())`}, strings.NewReader("hi\nthere\n"), &stdout, &stderr)
+
+	assert.Equal(t, 0, code)
+	assert.Equal(t, "? hi!\nthere\n", stdout.String())
+	assert.Empty(t, stderr.String())
+}
+
+func TestRunExit(t *testing.T) {
+	// run returns the code instead of exiting: main passes it to os.Exit
+	tests := []struct {
+		code     string
+		exitCode int
+		expected string
+	}{
+		{`puts("a"); exit(3); puts("b")`, 3, "a\n"},
+		{`exit()`, 0, ""},
+		{`let f = fn(x) { if (x > 1) { exit(x) } }; f(1); f(2); 5`, 2, ""},
+	}
+	for _, tt := range tests {
+		var stdout, stderr bytes.Buffer
+
+		code := run([]string{"monkey", "-eval", tt.code}, nil, &stdout, &stderr)
+
+		assert.Equal(t, tt.exitCode, code, tt.code)
+		assert.Equal(t, tt.expected, stdout.String(), tt.code)
+		assert.Empty(t, stderr.String(), tt.code)
+	}
+}
+
+func TestRunUsage(t *testing.T) {
+	for _, args := range [][]string{
+		{"monkey", "a.monkey", "b.monkey"},
+		{"monkey", "-eval", "1", "a.monkey"},
+		{"monkey", "-nope"},
+	} {
+		var stdout, stderr bytes.Buffer
+
+		code := run(args, nil, &stdout, &stderr)
+
+		assert.Equal(t, 2, code, args)
+		assert.Contains(t, stderr.String(), "usage: monkey", args)
+		assert.Empty(t, stdout.String())
+	}
+}
+
+func TestRunExampleScript(t *testing.T) {
+	var stdout, stderr bytes.Buffer
+
+	code := run([]string{"monkey", "resources/code_snippets/example.monkey"}, nil, &stdout, &stderr)
+
+	assert.Equal(t, 0, code)
+	assert.Equal(t, "[2, 4, 6]\nsomething else\n10\nis there anybody out there ?\n", stdout.String())
+	assert.Empty(t, stderr.String())
+}
+
+// helpers
+
+func writeScript(t *testing.T, code string) string {
+	path := filepath.Join(t.TempDir(), "script.monkey")
+	if err := os.WriteFile(path, []byte(code), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
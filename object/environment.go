@@ -1,5 +1,7 @@
 package object
 
+import "sort"
+
 type Environment struct {
 	store map[string]Object
 	outer *Environment
@@ -30,3 +32,47 @@ func (e *Environment) Set(name string, val Object) Object {
 	e.store[name] = val
 	return val
 }
+
+// Delete removes `name` from this scope only (outer scopes are untouched),
+// and reports whether it was there
+func (e *Environment) Delete(name string) bool {
+	_, ok := e.store[name]
+	delete(e.store, name)
+	return ok
+}
+
+// Names returns the names defined in this scope (not the outer ones), sorted
+func (e *Environment) Names() []string {
+	names := make([]string, 0, len(e.store))
+	for name := range e.store {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Assign updates `name` in the nearest scope defining it (unlike Set,
+// which always writes to this scope), and reports whether it found it
+func (e *Environment) Assign(name string, val Object) bool {
+	if _, ok := e.store[name]; ok {
+		e.store[name] = val
+		return true
+	}
+	if e.outer != nil {
+		return e.outer.Assign(name, val)
+	}
+	return false
+}
+
+// Snapshot returns every binding visible from this scope, outer scopes
+// included; an inner binding shadows an outer one with the same name
+func (e *Environment) Snapshot() map[string]Object {
+	snapshot := map[string]Object{}
+	if e.outer != nil {
+		snapshot = e.outer.Snapshot()
+	}
+	for name, val := range e.store {
+		snapshot[name] = val
+	}
+	return snapshot
+}
@@ -0,0 +1,90 @@
+package object
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestEnvironmentDelete(t *testing.T) {
+	outer := NewEnvironment()
+	outer.Set("x", &Integer{Value: 1})
+	inner := NewEnclosedEnvironment(outer)
+	inner.Set("x", &Integer{Value: 2})
+
+	// deletes the inner x, so the outer one is visible again
+	assert.True(t, inner.Delete("x"))
+	val, ok := inner.Get("x")
+	assert.True(t, ok)
+	assert.Equal(t, int64(1), val.(*Integer).Value)
+
+	// the outer x isn't in this scope, so it can't be deleted from here
+	assert.False(t, inner.Delete("x"))
+	_, ok = outer.Get("x")
+	assert.True(t, ok)
+
+	assert.False(t, outer.Delete("y"))
+	assert.True(t, outer.Delete("x"))
+	_, ok = inner.Get("x")
+	assert.False(t, ok)
+}
+
+func TestEnvironmentNames(t *testing.T) {
+	outer := NewEnvironment()
+	outer.Set("b", &Integer{Value: 1})
+	outer.Set("a", &Integer{Value: 2})
+	inner := NewEnclosedEnvironment(outer)
+
+	assert.Equal(t, []string{"a", "b"}, outer.Names())
+	assert.Equal(t, []string{}, inner.Names()) // only the local names
+
+	inner.Set("c", &Integer{Value: 3})
+	assert.Equal(t, []string{"c"}, inner.Names())
+}
+
+func TestEnvironmentAssign(t *testing.T) {
+	outer := NewEnvironment()
+	outer.Set("x", &Integer{Value: 1})
+	inner := NewEnclosedEnvironment(outer)
+
+	// updates the outer x, without creating an inner one
+	assert.True(t, inner.Assign("x", &Integer{Value: 2}))
+	assert.Equal(t, []string{}, inner.Names())
+	val, _ := outer.Get("x")
+	assert.Equal(t, int64(2), val.(*Integer).Value)
+
+	// an inner x shadows the outer one
+	inner.Set("x", &Integer{Value: 3})
+	assert.True(t, inner.Assign("x", &Integer{Value: 4}))
+	val, _ = outer.Get("x")
+	assert.Equal(t, int64(2), val.(*Integer).Value)
+
+	assert.False(t, inner.Assign("y", &Integer{Value: 5}))
+	_, ok := inner.Get("y")
+	assert.False(t, ok)
+}
+
+func TestEnvironmentSnapshot(t *testing.T) {
+	global := NewEnvironment()
+	global.Set("a", &Integer{Value: 1})
+	global.Set("b", &Integer{Value: 2})
+	outer := NewEnclosedEnvironment(global)
+	outer.Set("c", &Integer{Value: 3})
+	inner := NewEnclosedEnvironment(outer)
+	inner.Set("a", &String{Value: "shadowed"})
+
+	snapshot := inner.Snapshot()
+	assert.Len(t, snapshot, 3)
+	assert.Equal(t, `"shadowed"`, snapshot["a"].Inspect())
+	assert.Equal(t, "2", snapshot["b"].Inspect())
+	assert.Equal(t, "3", snapshot["c"].Inspect())
+
+	// only what's visible from there
+	assert.Len(t, outer.Snapshot(), 3)
+	assert.Equal(t, "1", outer.Snapshot()["a"].Inspect())
+	assert.Len(t, global.Snapshot(), 2)
+
+	// a copy: changing it doesn't change the environment
+	delete(snapshot, "b")
+	_, ok := inner.Get("b")
+	assert.True(t, ok)
+}
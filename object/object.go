@@ -3,7 +3,10 @@ package object
 import (
 	"bytes"
 	"fmt"
+	"hash/fnv"
 	"monkey/ast"
+	"sort"
+	"strconv"
 	"strings"
 )
 
@@ -11,10 +14,12 @@ type ObjectType string
 
 const (
 	INTEGER_OBJ      = "INTEGER"
+	FLOAT_OBJ        = "FLOAT"
 	BOOLEAN_OBJ      = "BOOLEAN"
 	NULL_OBJ         = "NULL"
 	RETURN_VALUE_OBJ = "RETURN_VALUE"
 	ERROR_OBJ        = "ERROR"
+	EXIT_OBJ         = "EXIT"
 	FUNCTION_OBJ     = "FUNCTION"
 	STRING_OBJ       = "STRING"
 	BUILTIN_OBJ      = "BUILTIN"
@@ -22,11 +27,24 @@ const (
 	HASHMAP_OBJ      = "HASHMAP"
 )
 
+// Inspect is the canonical representation of an object: it's what the REPL
+// echoes, and it's also used for the elements of arrays and hashmaps.
+// Strings are quoted, so that `"1"` and `1` can be told apart.
 type Object interface {
 	Type() ObjectType
 	Inspect() string
 }
 
+// ToString is what `puts` and `str` print: same as Inspect, except
+// that a top-level string is rendered as-is, without quotes
+// (so `puts("hi")` prints hi, but `puts(["hi"])` prints ["hi"])
+func ToString(obj Object) string {
+	if s, ok := obj.(*String); ok {
+		return s.Value
+	}
+	return obj.Inspect()
+}
+
 // INTEGER
 type Integer struct {
 	Value int64
@@ -37,11 +55,26 @@ type String struct {
 }
 
 func (s *String) Type() ObjectType { return STRING_OBJ }
-func (s *String) Inspect() string  { return s.Value }
+func (s *String) Inspect() string  { return strconv.Quote(s.Value) }
 
 func (i *Integer) Inspect() string  { return fmt.Sprintf("%d", i.Value) }
 func (i *Integer) Type() ObjectType { return INTEGER_OBJ }
 
+// FLOAT
+type Float struct {
+	Value float64
+}
+
+func (f *Float) Type() ObjectType { return FLOAT_OBJ }
+func (f *Float) Inspect() string {
+	s := strconv.FormatFloat(f.Value, 'g', -1, 64)
+	// always show it's a float, so that 4.0 doesn't look like the integer 4
+	if !strings.ContainsAny(s, ".eIN") {
+		s += ".0"
+	}
+	return s
+}
+
 // BOOLEAN
 type Boolean struct {
 	Value bool
@@ -64,17 +97,36 @@ type ReturnValue struct {
 func (rv *ReturnValue) Type() ObjectType { return RETURN_VALUE_OBJ }
 func (rv *ReturnValue) Inspect() string  { return rv.Value.Inspect() }
 
+// EXIT, from `exit(code)`: like an error it stops the evaluation, all the way
+// up to whoever runs the program, which then exits with Code
+type Exit struct {
+	Code int
+}
+
+func (e *Exit) Type() ObjectType { return EXIT_OBJ }
+func (e *Exit) Inspect() string  { return fmt.Sprintf("exit(%d)", e.Code) }
+
 // ERROR
 type Error struct {
 	Message string
+	Line    int // where the error happened, 0 if unknown
 }
 
 func (e *Error) Type() ObjectType { return ERROR_OBJ }
-func (e *Error) Inspect() string  { return "ERROR: " + e.Message }
+func (e *Error) Inspect() string  { return "ERROR: " + e.Describe() }
+
+// Describe is the message, prefixed by the line if we know it
+func (e *Error) Describe() string {
+	if e.Line > 0 {
+		return fmt.Sprintf("line %d: %s", e.Line, e.Message)
+	}
+	return e.Message
+}
 
 // FUNCTION
 type Function struct {
 	Parameters []*ast.Identifier
+	Defaults   []ast.Expression // same as in ast.FunctionLiteral
 	Body       *ast.BlockStatement
 	Env        *Environment
 }
@@ -85,8 +137,12 @@ func (f *Function) Type() ObjectType {
 func (f *Function) Inspect() string {
 	var out bytes.Buffer
 	params := []string{}
-	for _, p := range f.Parameters {
-		params = append(params, p.String())
+	for i, p := range f.Parameters {
+		if i < len(f.Defaults) && f.Defaults[i] != nil {
+			params = append(params, p.String()+" = "+f.Defaults[i].String())
+		} else {
+			params = append(params, p.String())
+		}
 	}
 	out.WriteString("fn")
 	out.WriteString("(")
@@ -125,10 +181,68 @@ func (ao *Array) Inspect() string {
 	return out.String()
 }
 
+// HASH KEYS
+// only strings, integers and booleans can be used as keys in a hashmap;
+// the type is part of the key, so 1 and true are different keys
+type HashKey struct {
+	Type  ObjectType
+	Value uint64
+}
+
+type Hashable interface {
+	Object
+	HashKey() HashKey
+}
+
+func (i *Integer) HashKey() HashKey {
+	return HashKey{Type: i.Type(), Value: uint64(i.Value)}
+}
+
+func (b *Boolean) HashKey() HashKey {
+	var value uint64
+	if b.Value {
+		value = 1
+	}
+	return HashKey{Type: b.Type(), Value: value}
+}
+
+func (s *String) HashKey() HashKey {
+	h := fnv.New64a()
+	h.Write([]byte(s.Value))
+	return HashKey{Type: s.Type(), Value: h.Sum64()}
+}
+
 // HASHMAPS
+// the original key is kept next to its value, for Inspect and iteration
+type HashPair struct {
+	Key   Object
+	Value Object
+}
+
 type HashMap struct {
-	Pairs map[string]Object
+	Pairs map[HashKey]HashPair
 }
 
 func (hm *HashMap) Type() ObjectType { return HASHMAP_OBJ }
-func (hm *HashMap) Inspect() string  { return "hashmap!" }
+func (hm *HashMap) Inspect() string {
+	// sort by key, so the output doesn't depend on the map iteration order
+	keys := map[string]HashPair{}
+	sorted := []string{}
+	for _, pair := range hm.Pairs {
+		k := pair.Key.Inspect()
+		keys[k] = pair
+		sorted = append(sorted, k)
+	}
+	sort.Strings(sorted)
+
+	pairs := []string{}
+	for _, k := range sorted {
+		pairs = append(pairs, k+": "+keys[k].Value.Inspect())
+	}
+
+	var out bytes.Buffer
+	out.WriteString("{")
+	out.WriteString(strings.Join(pairs, ", "))
+	out.WriteString("}")
+	return out.String()
+}
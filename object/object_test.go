@@ -0,0 +1,22 @@
+package object
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestHashKey(t *testing.T) {
+	hello1 := &String{Value: "Hello World"}
+	hello2 := &String{Value: "Hello World"}
+	diff := &String{Value: "My name is johnny"}
+	assert.Equal(t, hello1.HashKey(), hello2.HashKey())
+	assert.NotEqual(t, hello1.HashKey(), diff.HashKey())
+
+	assert.Equal(t, (&Integer{Value: 1}).HashKey(), (&Integer{Value: 1}).HashKey())
+	assert.Equal(t, (&Boolean{Value: true}).HashKey(), (&Boolean{Value: true}).HashKey())
+	assert.NotEqual(t, (&Boolean{Value: true}).HashKey(), (&Boolean{Value: false}).HashKey())
+
+	// same value, different types
+	assert.NotEqual(t, (&Integer{Value: 1}).HashKey(), (&Boolean{Value: true}).HashKey())
+	assert.NotEqual(t, (&Integer{Value: 0}).HashKey(), (&Boolean{Value: false}).HashKey())
+}
@@ -0,0 +1,145 @@
+package optimizer
+
+import (
+	"monkey/ast"
+	"monkey/evaluator"
+	"monkey/object"
+	"monkey/token"
+	"strconv"
+)
+
+// Fold replaces the infix and prefix expressions whose operands are all
+// integer, boolean or string literals, like `2 * 3 + 4`, with the literal
+// they evaluate to, `10`. Anything involving an identifier or a call is
+// left alone, and so are the operations that would fail (`1 / 0`): the
+// error is still reported when the program runs, with its line.
+//
+// The tree is modified in place; the returned node is the replacement for
+// node, which is node itself unless node is folded
+func Fold(node ast.Node) ast.Node {
+	switch node := node.(type) {
+	case *ast.Program:
+		for _, s := range node.Statements {
+			Fold(s)
+		}
+	case *ast.BlockStatement:
+		foldBlock(node)
+	case *ast.ExpressionStatement:
+		node.Expression = foldExpression(node.Expression)
+	case *ast.LetStatement:
+		node.Value = foldExpression(node.Value)
+	case *ast.ReturnStatement:
+		node.ReturnValue = foldExpression(node.ReturnValue)
+	case *ast.PrefixExpression:
+		node.Right = foldExpression(node.Right)
+		if right, ok := literalValue(node.Right); ok {
+			return toLiteral(evaluator.EvalPrefix(node.Operator, right), node, node.Token)
+		}
+	case *ast.InfixExpression:
+		node.Left = foldExpression(node.Left)
+		node.Right = foldExpression(node.Right)
+		left, leftOk := literalValue(node.Left)
+		right, rightOk := literalValue(node.Right)
+		if leftOk && rightOk {
+			return toLiteral(evaluator.EvalInfix(node.Operator, left, right), node, node.Token)
+		}
+	case *ast.ReassignmentExpression:
+		node.Right = foldExpression(node.Right)
+	case *ast.IfExpression:
+		node.Condition = foldExpression(node.Condition)
+		foldBlock(node.Consequence)
+		foldBlock(node.Alternative)
+	case *ast.WhileExpression:
+		node.Condition = foldExpression(node.Condition)
+		foldBlock(node.Body)
+		foldBlock(node.Else)
+	case *ast.DoWhileExpression:
+		foldBlock(node.Body)
+		node.Condition = foldExpression(node.Condition)
+	case *ast.TryExpression:
+		foldBlock(node.Body)
+		foldBlock(node.Catch)
+	case *ast.ForLoop:
+		foldExpressions(node.Elements)
+		node.Ident = foldExpression(node.Ident)
+		foldBlock(node.Body)
+	case *ast.FunctionLiteral:
+		foldExpressions(node.Defaults)
+		foldBlock(node.Body)
+	case *ast.CallExpression:
+		node.Function = foldExpression(node.Function)
+		foldExpressions(node.Arguments)
+	case *ast.MapFunction:
+		node.Function = foldExpression(node.Function)
+		foldExpressions(node.Elements)
+	case *ast.InterpolatedString:
+		foldExpressions(node.Parts)
+	case *ast.ArrayLiteral:
+		foldExpressions(node.Elements)
+	case *ast.IndexExpression:
+		node.Left = foldExpression(node.Left)
+		node.Index = foldExpression(node.Index)
+	case *ast.HashLiteral:
+		pairs := make(map[ast.Expression]ast.Expression, len(node.Pairs))
+		for key, value := range node.Pairs {
+			pairs[foldExpression(key)] = foldExpression(value)
+		}
+		node.Pairs = pairs
+	}
+	return node
+}
+
+func foldExpression(exp ast.Expression) ast.Expression {
+	if exp == nil {
+		return nil
+	}
+	return Fold(exp).(ast.Expression)
+}
+
+func foldExpressions(exps []ast.Expression) {
+	for i, exp := range exps {
+		exps[i] = foldExpression(exp)
+	}
+}
+
+func foldBlock(block *ast.BlockStatement) {
+	if block == nil {
+		return
+	}
+	for _, s := range block.Statements {
+		Fold(s)
+	}
+}
+
+// literalValue is the object a literal evaluates to
+func literalValue(exp ast.Expression) (object.Object, bool) {
+	switch exp := exp.(type) {
+	case *ast.IntegerLiteral:
+		return &object.Integer{Value: exp.Value}, true
+	case *ast.Boolean:
+		return &object.Boolean{Value: exp.Value}, true
+	case *ast.StringLiteral:
+		return &object.String{Value: exp.Value}, true
+	}
+	return nil, false
+}
+
+// toLiteral turns the result of a folded operation back into a literal,
+// on the line of the operator; if it can't (an error, a float), the
+// original expression is kept
+func toLiteral(obj object.Object, original ast.Expression, tok token.Token) ast.Expression {
+	switch obj := obj.(type) {
+	case *object.Integer:
+		literal := strconv.FormatInt(obj.Value, 10)
+		return &ast.IntegerLiteral{Token: token.Token{Type: token.INT, Literal: literal, Line: tok.Line}, Value: obj.Value}
+	case *object.Boolean:
+		var tokenType token.TokenType = token.FALSE
+		if obj.Value {
+			tokenType = token.TRUE
+		}
+		return &ast.Boolean{Token: token.Token{Type: tokenType, Literal: obj.Inspect(), Line: tok.Line}, Value: obj.Value}
+	case *object.String:
+		return &ast.StringLiteral{Token: token.Token{Type: token.STRING, Literal: obj.Value, Line: tok.Line}, Value: obj.Value}
+	}
+	return original
+}
@@ -0,0 +1,72 @@
+package optimizer
+
+import (
+	"github.com/stretchr/testify/assert"
+	"monkey/ast"
+	"monkey/lexer"
+	"monkey/parser"
+	"testing"
+)
+
+func TestFold(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"2 * 3 + 4", "10"},
+		{"-(1 + 2)", "-3"},
+		{"1 < 2 == true", "true"},
+		{"!true", "false"},
+		{`"a" + "b"`, "ab"},
+		{"7 ~/ 2", "3"},
+		// only the constant parts
+		{"x + 1", "(x + 1)"},
+		{"x + 2 * 3", "(x + 6)"},
+		{"(1 + 2) + x + (3 + 4)", "((3 + x) + 7)"},
+		{"f(1 + 1, x * (2 - 2))", "f(2, (x * 0))"},
+		{"let y = 10 * 10", "let y = 100;"},
+		{"fn(x) { x * (2 + 2) }", "fn(x) (x * 4)"},
+		{"if (1 < 2) { 3 + 3 } else { x }", "iftrue 6else x"},
+		{"[1 + 1, 2 * 2][0 + 1]", "([2, 4][1])"},
+		// errors are left to the evaluator
+		{"1 / 0", "(1 / 0)"},
+		{"1 + true", "(1 + true)"},
+		{"9223372036854775807 + 1", "(9223372036854775807 + 1)"},
+	}
+	for _, tt := range tests {
+		program := parse(t, tt.input)
+		assert.Same(t, program, Fold(program))
+		assert.Equal(t, tt.expected, program.String(), tt.input)
+	}
+}
+
+func TestFoldLiteralTypes(t *testing.T) {
+	program := parse(t, `1 + 2; 1 == 2; "a" + "b"`)
+	Fold(program)
+
+	integer := program.Statements[0].(*ast.ExpressionStatement).Expression.(*ast.IntegerLiteral)
+	assert.Equal(t, int64(3), integer.Value)
+	boolean := program.Statements[1].(*ast.ExpressionStatement).Expression.(*ast.Boolean)
+	assert.False(t, boolean.Value)
+	str := program.Statements[2].(*ast.ExpressionStatement).Expression.(*ast.StringLiteral)
+	assert.Equal(t, "ab", str.Value)
+	assert.Equal(t, 1, str.Token.Line)
+}
+
+func TestFoldKeepsIdentifiers(t *testing.T) {
+	program := parse(t, "x + 1")
+	exp := program.Statements[0].(*ast.ExpressionStatement).Expression
+	Fold(program)
+	assert.Same(t, exp, program.Statements[0].(*ast.ExpressionStatement).Expression)
+}
+
+// helpers
+
+func parse(t *testing.T, input string) *ast.Program {
+	p := parser.New(lexer.New(input))
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		t.Fatalf("parser errors: %v", p.Errors())
+	}
+	return program
+}
@@ -1,11 +1,13 @@
 package parser
 
 import (
+	"errors"
 	"fmt"
 	"monkey/ast"
 	"monkey/lexer"
 	"monkey/token"
 	"strconv"
+	"strings"
 )
 
 type Parser struct {
@@ -15,6 +17,7 @@ type Parser struct {
 	errors         []string
 	prefixParseFns map[token.TokenType]prefixParseFn
 	infixParseFns  map[token.TokenType]infixParseFn
+	recovered      int // len(errors) after the last skipStatement
 }
 
 func New(l *lexer.Lexer) *Parser {
@@ -24,11 +27,13 @@ func New(l *lexer.Lexer) *Parser {
 	p.prefixParseFns = make(map[token.TokenType]prefixParseFn)
 	p.registerPrefix(token.IDENT, p.parseIdentifier)
 	p.registerPrefix(token.INT, p.parseInteger)
+	p.registerPrefix(token.FLOAT, p.parseFloat)
 	p.registerPrefix(token.STRING, p.parseString)
 	p.registerPrefix(token.TRUE, p.parseBoolean)
 	p.registerPrefix(token.FALSE, p.parseBoolean)
 	p.registerPrefix(token.BANG, p.parsePrefixExpression)
 	p.registerPrefix(token.MINUS, p.parsePrefixExpression)
+	p.registerPrefix(token.TILDE, p.parsePrefixExpression)
 	p.registerPrefix(token.LPAREN, p.parseGroupedExpression)
 	p.registerPrefix(token.IF, p.parseIfExpression)
 	p.registerPrefix(token.FUNCTION, p.parseFunctionExpression)
@@ -36,6 +41,8 @@ func New(l *lexer.Lexer) *Parser {
 	p.registerPrefix(token.LBRACE, p.parseHashLiteral)
 	p.registerPrefix(token.MAP, p.parseMapFunction)
 	p.registerPrefix(token.WHILE, p.parseWhileExpression)
+	p.registerPrefix(token.DO, p.parseDoWhileExpression)
+	p.registerPrefix(token.TRY, p.parseTryExpression)
 	p.registerPrefix(token.FOR, p.parseForLoop)
 
 	// register INFIX parse functions
@@ -44,12 +51,19 @@ func New(l *lexer.Lexer) *Parser {
 	p.registerInfix(token.MINUS, p.parseInfixExpression)
 	p.registerInfix(token.SLASH, p.parseInfixExpression)
 	p.registerInfix(token.ASTERISK, p.parseInfixExpression)
+	p.registerInfix(token.INT_DIV, p.parseInfixExpression)
 	p.registerInfix(token.EQ, p.parseInfixExpression)
 	p.registerInfix(token.NOT_EQ, p.parseInfixExpression)
 	p.registerInfix(token.LT, p.parseInfixExpression)
 	p.registerInfix(token.GT, p.parseInfixExpression)
+	p.registerInfix(token.LT_EQ, p.parseInfixExpression)
+	p.registerInfix(token.GT_EQ, p.parseInfixExpression)
 	p.registerInfix(token.LPAREN, p.parseCallExpression)
 	p.registerInfix(token.LBRACKET, p.parseIndexExpression)
+	p.registerInfix(token.DOT, p.parseMethodCall)
+	p.registerInfix(token.AND, p.parseInfixExpression)
+	p.registerInfix(token.OR, p.parseInfixExpression)
+	p.registerInfix(token.ASSIGN, p.parseReassignment)
 
 	// read two tokens so curToken and peekToken are both set
 	p.nextToken()
@@ -76,16 +90,41 @@ func (p *Parser) ParseProgram() *ast.Program {
 	program := &ast.Program{} // the root node of every AST
 
 	for p.curToken.Type != token.EOF {
+		errs := len(p.errors)
 		stmt := p.parseStatement()
 		if stmt != nil {
 			program.Statements = append(program.Statements, stmt)
 		}
+		if p.failedSince(errs) {
+			p.skipStatement(token.EOF)
+		}
 		p.nextToken()
 	}
 
 	return program
 }
 
+// failedSince tells whether there are new errors since there were errs,
+// which haven't been dealt with by skipStatement (in an inner block)
+func (p *Parser) failedSince(errs int) bool {
+	if p.recovered > errs {
+		errs = p.recovered
+	}
+	return len(p.errors) > errs
+}
+
+// skipStatement skips what's left of a statement that failed to parse: up to
+// its `;`, or to where the next `let` or `return`, or end, begins; so that
+// one mistake is reported once, not followed by a flood of confusing errors
+func (p *Parser) skipStatement(end token.TokenType) {
+	for !p.curTokenIs(token.SEMICOLON) && !p.curTokenIs(token.EOF) &&
+		!p.peekTokenIs(token.LET) && !p.peekTokenIs(token.RETURN) &&
+		!p.peekTokenIs(end) && !p.peekTokenIs(token.EOF) {
+		p.nextToken()
+	}
+	p.recovered = len(p.errors)
+}
+
 func (p *Parser) parseStatement() ast.Statement {
 	switch p.curToken.Type {
 	case token.LET:
@@ -102,15 +141,24 @@ func (p *Parser) parseStatement() ast.Statement {
 func (p *Parser) parseLetStatement() *ast.LetStatement {
 	stmt := &ast.LetStatement{Token: p.curToken}
 
-	// after `let`, next token is an identifier (variable)
-	if !p.expectPeek(token.IDENT) {
-		return nil
-	}
+	if p.peekTokenIs(token.LBRACKET) {
+		// destructuring: `let [a, b] = ...`
+		p.nextToken() // move to [
+		stmt.Names = p.parseIdentifierList(token.RBRACKET)
+		if stmt.Names == nil {
+			return nil
+		}
+	} else {
+		// after `let`, next token is an identifier (variable)
+		if !p.expectPeek(token.IDENT) {
+			return nil
+		}
 
-	// create identifier based on it
-	stmt.Name = &ast.Identifier{
-		Token: p.curToken,
-		Value: p.curToken.Literal,
+		// create identifier based on it
+		stmt.Name = &ast.Identifier{
+			Token: p.curToken,
+			Value: p.curToken.Literal,
+		}
 	}
 
 	// after `let $xxx`, next token is `=`; error if not
@@ -130,14 +178,35 @@ func (p *Parser) parseLetStatement() *ast.LetStatement {
 	return stmt
 }
 
+// parses `a, b, c]` (curToken is the opening token) into identifiers;
+// there must be at least one
+func (p *Parser) parseIdentifierList(end token.TokenType) []*ast.Identifier {
+	idents := []*ast.Identifier{}
+	for {
+		if !p.expectPeek(token.IDENT) {
+			return nil
+		}
+		idents = append(idents, &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal})
+		if !p.peekTokenIs(token.COMMA) {
+			break
+		}
+		p.nextToken() // move to the comma
+	}
+	if !p.expectPeek(end) {
+		return nil
+	}
+	return idents
+}
+
 func (p *Parser) parseReturnStatement() *ast.ReturnStatement {
 	stmt := &ast.ReturnStatement{Token: p.curToken}
 	p.nextToken()
 
 	stmt.ReturnValue = p.parseExpression(LOWEST)
 
-	// skip semicolon if any
-	if p.curTokenIs(token.SEMICOLON) {
+	// skip semicolon if any; like in parseLetStatement, the token
+	// is on the end of the exp, so the `;` is the next one
+	if p.peekTokenIs(token.SEMICOLON) {
 		p.nextToken()
 	}
 
@@ -208,6 +277,14 @@ func (p *Parser) parseExpression(precedence int) ast.Expression {
 	// the current token type; the first element of an exp is always one of
 	// IDENT, INT, BANG, MINUS
 	prefix := p.prefixParseFns[p.curToken.Type]
+	if p.curTokenIs(token.ILLEGAL) && strings.HasPrefix(p.curToken.Literal, `"`) {
+		p.errors = append(p.errors, "unterminated string literal")
+		return nil
+	}
+	if p.curTokenIs(token.ILLEGAL) {
+		p.errors = append(p.errors, fmt.Sprintf("unexpected character '%s'", p.curToken.Literal))
+		return nil
+	}
 	if prefix == nil {
 		p.errors = append(p.errors, fmt.Sprintf("no prefix parse function found for %s", p.curToken.Type))
 		return nil
@@ -229,26 +306,36 @@ func (p *Parser) parseExpression(precedence int) ast.Expression {
 const (
 	_ int = iota
 	LOWEST
+	ASSIGN      // x = 5
+	OR          // ||
+	AND         // &&
 	EQUALS      // ==
-	LESSGREATER // > or <
+	LESSGREATER // >, <, >= or <=
 	SUM         // +
-	PRODUCT     // *
+	PRODUCT     // *, / or ~/
 	PREFIX      // -X or !X
 	CALL        // myFunction(X)
 	INDEX       // array[index]
 )
 
 var precedences = map[token.TokenType]int{
+	token.ASSIGN:   ASSIGN,
+	token.OR:       OR,
+	token.AND:      AND,
 	token.EQ:       EQUALS,
 	token.NOT_EQ:   EQUALS,
 	token.LT:       LESSGREATER,
 	token.GT:       LESSGREATER,
+	token.LT_EQ:    LESSGREATER,
+	token.GT_EQ:    LESSGREATER,
 	token.PLUS:     SUM,
 	token.MINUS:    SUM,
 	token.SLASH:    PRODUCT,
 	token.ASTERISK: PRODUCT,
+	token.INT_DIV:  PRODUCT,
 	token.LPAREN:   CALL,
 	token.LBRACKET: INDEX,
+	token.DOT:      INDEX,
 }
 
 // get precedence for peek token (next token)
@@ -268,39 +355,187 @@ func (p *Parser) curPrecedence() int {
 }
 
 func (p *Parser) parseIdentifier() ast.Expression {
-	// normal case, just an identifier
-	if !p.peekTokenIs(token.ASSIGN) {
-		return &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
-	}
-	// we are reassigning a value to an identifier, e.g. `x = 5`
-	// current token is `x`
-	left := &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+	return &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+}
 
-	p.nextToken() // move to `=`
-	tk := p.curToken
+// we are reassigning a value to an identifier, e.g. `x = 5`; curToken is `=`
+func (p *Parser) parseReassignment(left ast.Expression) ast.Expression {
+	ident, ok := left.(*ast.Identifier)
+	if !ok {
+		if left == nil {
+			return nil // the error is already reported
+		}
+		// not left.String(): after an error, it may be missing parts
+		what := "an expression"
+		switch left.(type) {
+		case *ast.IntegerLiteral, *ast.FloatLiteral, *ast.Boolean:
+			what = left.TokenLiteral()
+		}
+		p.errors = append(p.errors, fmt.Sprintf("cannot assign to %s, only to a name", what))
+		return nil
+	}
+	exp := &ast.ReassignmentExpression{Token: p.curToken, Left: ident}
 
-	// move to next token and parse it as an expression
+	// assignment is right-associative: in `a = b = 1` the right side is `b = 1`
 	p.nextToken()
-	right := p.parseExpression(LOWEST)
-
-	return &ast.ReassignmentExpression{
-		Token: tk,
-		Left:  left,
-		Right: right,
-	}
+	exp.Right = p.parseExpression(ASSIGN - 1)
+	return exp
 }
 
 func (p *Parser) parseInteger() ast.Expression {
-	val, err := strconv.ParseInt(p.curToken.Literal, 0, 64)
+	literal := p.curToken.Literal
+	// underscores are only allowed between digits: 1_000 is ok, 1__000 and 1000_ are not
+	if strings.HasSuffix(literal, "_") || strings.Contains(literal, "__") {
+		p.errors = append(p.errors, fmt.Sprintf("invalid underscore in number %s", literal))
+		return nil
+	}
+	val, err := strconv.ParseInt(strings.ReplaceAll(literal, "_", ""), 0, 64)
+	if errors.Is(err, strconv.ErrRange) {
+		p.errors = append(p.errors, fmt.Sprintf("integer %s is out of range: integers are 64 bits", literal))
+		return nil
+	}
 	if err != nil {
-		p.errors = append(p.errors, fmt.Sprintf("cannot parse %s as integer", p.curToken.Literal))
+		p.errors = append(p.errors, fmt.Sprintf("cannot parse %s as integer", literal))
+		return nil
 	}
 
 	return &ast.IntegerLiteral{Token: p.curToken, Value: val}
 }
 
+func (p *Parser) parseFloat() ast.Expression {
+	literal := p.curToken.Literal
+	// same rules as for integers
+	if strings.HasSuffix(literal, "_") || strings.Contains(literal, "__") ||
+		strings.Contains(literal, "_.") || strings.Contains(literal, "._") ||
+		strings.Contains(literal, "_e") || strings.Contains(literal, "_E") {
+		p.errors = append(p.errors, fmt.Sprintf("invalid underscore in number %s", literal))
+		return nil
+	}
+	val, err := strconv.ParseFloat(strings.ReplaceAll(literal, "_", ""), 64)
+	if err != nil {
+		p.errors = append(p.errors, fmt.Sprintf("cannot parse %s as float", literal))
+		return nil
+	}
+
+	return &ast.FloatLiteral{Token: p.curToken, Value: val}
+}
+
 func (p *Parser) parseString() ast.Expression {
-	return &ast.StringLiteral{Token: p.curToken, Value: p.curToken.Literal}
+	literal := p.curToken.Literal
+	if !strings.Contains(literal, "${") {
+		return &ast.StringLiteral{Token: p.curToken, Value: literal}
+	}
+
+	// interpolation: split `a ${x} b` into text and expressions;
+	// `\${` is a literal `${`
+	exp := &ast.InterpolatedString{Token: p.curToken}
+	var text strings.Builder
+	for i := 0; i < len(literal); i++ {
+		switch {
+		case strings.HasPrefix(literal[i:], `\${`):
+			text.WriteString("${")
+			i += 2
+		case strings.HasPrefix(literal[i:], "${"):
+			end := matchingBrace(literal, i+2)
+			if end < 0 {
+				p.errors = append(p.errors, "unterminated ${ in string")
+				return nil
+			}
+			// the line where the ${ is, for the errors in there
+			line := p.curToken.Line + strings.Count(literal[:i], "\n")
+			embedded := p.parseEmbedded(literal[i+2:end], line)
+			if embedded == nil {
+				return nil
+			}
+			if text.Len() > 0 {
+				exp.Parts = append(exp.Parts, p.textPart(text.String()))
+				text.Reset()
+			}
+			exp.Parts = append(exp.Parts, embedded)
+			i = end
+		default:
+			text.WriteByte(literal[i])
+		}
+	}
+	if text.Len() > 0 {
+		exp.Parts = append(exp.Parts, p.textPart(text.String()))
+	}
+	// only escaped ${ in there: it's just a string
+	if len(exp.Parts) == 1 {
+		if str, ok := exp.Parts[0].(*ast.StringLiteral); ok {
+			return str
+		}
+	}
+	return exp
+}
+
+// parseEmbedded parses the source of a ${...}, which must be one expression
+func (p *Parser) parseEmbedded(source string, line int) ast.Expression {
+	inner := New(lexer.NewAt(source, line))
+	program := inner.ParseProgram()
+	if len(inner.Errors()) > 0 {
+		p.errors = append(p.errors, inner.Errors()...)
+		return nil
+	}
+	if len(program.Statements) == 0 {
+		p.errors = append(p.errors, "empty ${} in string")
+		return nil
+	}
+	stmt, ok := program.Statements[0].(*ast.ExpressionStatement)
+	if !ok || len(program.Statements) > 1 {
+		p.errors = append(p.errors, fmt.Sprintf("expected one expression in ${%s}", source))
+		return nil
+	}
+	return stmt.Expression
+}
+
+func (p *Parser) textPart(text string) *ast.StringLiteral {
+	return &ast.StringLiteral{
+		Token: token.Token{Type: token.STRING, Literal: text, Line: p.curToken.Line},
+		Value: text,
+	}
+}
+
+// matchingBrace is the position of the } closing a ${ in s, skipping any
+// nested pair of braces (as in `${fn() { 1 }()}`) and strings (as in
+// `${h["}"]}`) from start on; -1 if none. Like the lexer's skipEmbedded
+func matchingBrace(s string, start int) int {
+	depth := 0
+	for i := start; i < len(s); i++ {
+		switch s[i] {
+		case '"':
+			i = closingQuote(s, i+1)
+			if i < 0 {
+				return -1
+			}
+		case '{':
+			depth++
+		case '}':
+			if depth == 0 {
+				return i
+			}
+			depth--
+		}
+	}
+	return -1
+}
+
+// closingQuote is the position of the " closing a string in s, which starts
+// at start; -1 if none. Like the lexer's skipString
+func closingQuote(s string, start int) int {
+	for i := start; i < len(s); i++ {
+		switch {
+		case s[i] == '"':
+			return i
+		case strings.HasPrefix(s[i:], `\${`):
+			i += 2
+		case strings.HasPrefix(s[i:], "${"):
+			if end := matchingBrace(s, i+2); end >= 0 {
+				i = end
+			}
+		}
+	}
+	return -1
 }
 
 func (p *Parser) parseBoolean() ast.Expression {
@@ -398,6 +633,59 @@ func (p *Parser) parseWhileExpression() ast.Expression {
 
 	// parse the whole { ... } block
 	exp.Body = p.parseBlockStatement()
+
+	if p.peekTokenIs(token.ELSE) {
+		p.nextToken() // move to `else`
+
+		// expect { and move on curToken
+		if !p.expectPeek(token.LBRACE) {
+			return nil
+		}
+		exp.Else = p.parseBlockStatement()
+	}
+	return exp
+}
+
+func (p *Parser) parseDoWhileExpression() ast.Expression {
+	exp := &ast.DoWhileExpression{Token: p.curToken}
+	// curToken is `do`; expect { and move on curToken
+	if !p.expectPeek(token.LBRACE) {
+		return nil
+	}
+	exp.Body = p.parseBlockStatement()
+
+	// then `while (cond)`
+	if !p.expectPeek(token.WHILE) {
+		return nil
+	}
+	if !p.expectPeek(token.LPAREN) {
+		return nil
+	}
+	p.nextToken() // curToken is `(`; move to the exp
+	exp.Condition = p.parseExpression(LOWEST)
+	if !p.expectPeek(token.RPAREN) {
+		return nil
+	}
+	return exp
+}
+
+func (p *Parser) parseTryExpression() ast.Expression {
+	exp := &ast.TryExpression{Token: p.curToken}
+	// curToken is `try`; expect { and move on curToken
+	if !p.expectPeek(token.LBRACE) {
+		return nil
+	}
+	exp.Body = p.parseBlockStatement()
+
+	// then `catch (e) { ... }`
+	if !p.expectPeek(token.CATCH) || !p.expectPeek(token.LPAREN) || !p.expectPeek(token.IDENT) {
+		return nil
+	}
+	exp.Ident = &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+	if !p.expectPeek(token.RPAREN) || !p.expectPeek(token.LBRACE) {
+		return nil
+	}
+	exp.Catch = p.parseBlockStatement()
 	return exp
 }
 
@@ -413,15 +701,20 @@ func (p *Parser) parseForLoop() ast.Expression {
 	if !p.expectPeek(token.IN) { // curToken is `in`
 		return nil
 	}
-	p.nextToken() // curToken is either `[` or an identifier
+	p.nextToken() // curToken is the start of an expression
 
-	if p.curTokenIs(token.LBRACKET) { // parse array literal
-		exp.Elements = p.parseExpressionList(token.RBRACKET)
-	} else { // parse identifier
-		exp.Ident = p.parseIdentifier()
+	// any exp: an identifier, a function call, `[1, 2] + [3]`...
+	// only a bare array literal goes into Elements
+	iterable := p.parseExpression(LOWEST)
+	if array, ok := iterable.(*ast.ArrayLiteral); ok {
+		exp.Elements = array.Elements
+	} else {
+		exp.Ident = iterable
 	}
 
-	p.nextToken() // curToken is `{`
+	if !p.expectPeek(token.LBRACE) { // curToken is `{`
+		return nil
+	}
 	exp.Body = p.parseBlockStatement()
 
 	return exp
@@ -437,10 +730,14 @@ func (p *Parser) parseBlockStatement() *ast.BlockStatement {
 
 	// go on until you find } or EOF
 	for !p.curTokenIs(token.RBRACE) && !p.curTokenIs(token.EOF) {
+		errs := len(p.errors)
 		stmt := p.parseStatement()
 		if stmt != nil {
 			block.Statements = append(block.Statements, stmt)
 		}
+		if p.failedSince(errs) {
+			p.skipStatement(token.RBRACE)
+		}
 		p.nextToken()
 	}
 	return block
@@ -449,6 +746,12 @@ func (p *Parser) parseBlockStatement() *ast.BlockStatement {
 func (p *Parser) parseFunctionExpression() ast.Expression {
 	exp := &ast.FunctionLiteral{Token: p.curToken}
 
+	// optional name, so that the function can call itself
+	if p.peekTokenIs(token.IDENT) {
+		p.nextToken()
+		exp.Name = &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+	}
+
 	// expect ( and move on it
 	if !p.expectPeek(token.LPAREN) {
 		return nil
@@ -465,6 +768,18 @@ func (p *Parser) parseFunctionExpression() ast.Expression {
 		// create identifier and add it to params
 		ident := &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
 		exp.Params = append(exp.Params, ident)
+
+		// optional default value, e.g. `y = 10`
+		var def ast.Expression
+		if p.peekTokenIs(token.ASSIGN) {
+			p.nextToken() // move to =
+			p.nextToken() // move to the exp
+			def = p.parseExpression(LOWEST)
+		} else if len(exp.Defaults) > 0 && exp.Defaults[len(exp.Defaults)-1] != nil {
+			// a param without default after one with a default could never be omitted
+			p.errors = append(p.errors, fmt.Sprintf("parameter %s must have a default value", ident.Value))
+		}
+		exp.Defaults = append(exp.Defaults, def)
 		p.nextToken()
 	}
 
@@ -488,6 +803,33 @@ func (p *Parser) parseCallExpression(function ast.Expression) ast.Expression {
 	return exp
 }
 
+// `receiver.name(args...)` is sugar for `name(receiver, args...)`,
+// where name is a builtin
+func (p *Parser) parseMethodCall(receiver ast.Expression) ast.Expression {
+	if receiver == nil {
+		return nil // the error is already reported
+	}
+	if !p.expectPeek(token.IDENT) { // curToken is `.`; move to the name
+		return nil
+	}
+	name := &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+	// there are no fields, only methods
+	if !p.peekTokenIs(token.LPAREN) {
+		// not receiver.String(): after an error, it may be missing parts
+		p.errors = append(p.errors, fmt.Sprintf("expected a method call after `.`, got .%s", name.Value))
+		return nil
+	}
+	p.nextToken() // move to `(`
+
+	exp := &ast.CallExpression{Token: p.curToken, Function: name, Method: true}
+	args := p.parseExpressionList(token.RPAREN)
+	if args == nil {
+		return nil
+	}
+	exp.Arguments = append([]ast.Expression{receiver}, args...)
+	return exp
+}
+
 func (p *Parser) parseArrayLiteral() ast.Expression {
 	array := &ast.ArrayLiteral{Token: p.curToken}
 	array.Elements = p.parseExpressionList(token.RBRACKET)
@@ -507,7 +849,10 @@ func (p *Parser) parseExpressionList(end token.TokenType) []ast.Expression {
 	args = append(args, p.parseExpression(LOWEST)) // parse exp
 
 	for p.peekTokenIs(token.COMMA) {
-		p.nextToken()                                  // move to the comma
+		p.nextToken() // move to the comma
+		if p.peekTokenIs(end) {
+			break // trailing comma: [1, 2, 3,]
+		}
 		p.nextToken()                                  // move to the next exp
 		args = append(args, p.parseExpression(LOWEST)) // parse exp
 	}
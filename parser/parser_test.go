@@ -74,6 +74,40 @@ func TestNewLetStatements(t *testing.T) {
 	}
 }
 
+func TestLetDestructuring(t *testing.T) {
+	input := `let [a, b, c] = [1, 2, 3];`
+	l := lexer.New(input)
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	assert.Len(t, program.Statements, 1)
+	stmt, ok := program.Statements[0].(*ast.LetStatement)
+	assert.True(t, ok)
+
+	assert.Nil(t, stmt.Name)
+	assert.Len(t, stmt.Names, 3)
+	testIdentifier(t, stmt.Names[0], "a")
+	testIdentifier(t, stmt.Names[1], "b")
+	testIdentifier(t, stmt.Names[2], "c")
+	assert.Equal(t, "let [a, b, c] = [1, 2, 3];", stmt.String())
+
+	errors := []struct {
+		input    string
+		expected string
+	}{
+		{"let [] = []", "expected next token to be IDENT, got ] instead"},
+		{"let [a, 1] = []", "expected next token to be IDENT, got INT instead"},
+		{"let [a b] = []", "expected next token to be ], got IDENT instead"},
+	}
+	for _, tt := range errors {
+		l := lexer.New(tt.input)
+		p := New(l)
+		p.ParseProgram()
+		assert.Contains(t, p.Errors(), tt.expected)
+	}
+}
+
 func TestNewReturnStatements(t *testing.T) {
 	tests := []struct {
 		input         string
@@ -81,6 +115,7 @@ func TestNewReturnStatements(t *testing.T) {
 	}{
 		{"return 5", 5},
 		{"return true", true},
+		{"return 5;", 5},
 	}
 
 	for _, tt := range tests {
@@ -189,6 +224,18 @@ func TestIdentifierExpression(t *testing.T) {
 	assert.Equal(t, "foobar", ident.TokenLiteral())
 }
 
+func TestIdentifierWithDigits(t *testing.T) {
+	p := New(lexer.New("let x2 = 5; x2"))
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	assert.Len(t, program.Statements, 2)
+	let := program.Statements[0].(*ast.LetStatement)
+	assert.Equal(t, "x2", let.Name.Value)
+	stmt := program.Statements[1].(*ast.ExpressionStatement)
+	testIdentifier(t, stmt.Expression, "x2")
+}
+
 func TestIntegerLiteralExpression(t *testing.T) {
 	input := "5;"
 
@@ -210,6 +257,78 @@ func TestIntegerLiteralExpression(t *testing.T) {
 	assert.Equal(t, "5", ident.TokenLiteral())
 }
 
+func TestIntegerLiteralsWithUnderscores(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected int64
+	}{
+		{"1_000_000", 1000000},
+		{"1_0", 10},
+		{"12_34_5", 12345},
+	}
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		stmt := program.Statements[0].(*ast.ExpressionStatement)
+		literal, ok := stmt.Expression.(*ast.IntegerLiteral)
+		assert.True(t, ok)
+		assert.Equal(t, tt.expected, literal.Value)
+	}
+
+	invalid := []struct {
+		input    string
+		expected string
+	}{
+		{"1__0", "invalid underscore in number 1__0"},
+		{"10_", "invalid underscore in number 10_"},
+		{"99999999999999999999999", "integer 99999999999999999999999 is out of range: integers are 64 bits"},
+		{"9_223_372_036_854_775_808", "integer 9_223_372_036_854_775_808 is out of range: integers are 64 bits"},
+	}
+	for _, tt := range invalid {
+		l := lexer.New(tt.input)
+		p := New(l)
+		p.ParseProgram()
+		assert.Equal(t, []string{tt.expected}, p.Errors())
+	}
+}
+
+func TestFloatLiteralExpression(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected float64
+	}{
+		{"1.5", 1.5},
+		{"0.25", 0.25},
+		{"1.5e3", 1500},
+		{"2E-2", 0.02},
+		{"1e3", 1000},
+		{"1E0", 1},
+		{"3.0e+2", 300},
+		{"2.5e-3", 0.0025},
+		{"1_000.5", 1000.5},
+	}
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		stmt := program.Statements[0].(*ast.ExpressionStatement)
+		literal, ok := stmt.Expression.(*ast.FloatLiteral)
+		assert.True(t, ok)
+		assert.Equal(t, tt.expected, literal.Value)
+		assert.Equal(t, tt.input, literal.TokenLiteral())
+	}
+
+	l := lexer.New("1_.5")
+	p := New(l)
+	p.ParseProgram()
+	assert.Equal(t, []string{"invalid underscore in number 1_.5"}, p.Errors())
+}
+
 func TestExpressionWithComments(t *testing.T) {
 	input := `
 		// this a comment
@@ -268,6 +387,71 @@ func TestStringLiteralExpression(t *testing.T) {
 	assert.Equal(t, "hello world", literal.Value)
 }
 
+func TestInterpolatedStringParsing(t *testing.T) {
+	l := lexer.New(`"x is ${x + 1}!"`)
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	stmt := program.Statements[0].(*ast.ExpressionStatement)
+	str, ok := stmt.Expression.(*ast.InterpolatedString)
+	assert.True(t, ok)
+	assert.Len(t, str.Parts, 3)
+	assert.Equal(t, "x is ", str.Parts[0].(*ast.StringLiteral).Value)
+	testInfixExpression(t, str.Parts[1], "x", "+", 1)
+	assert.Equal(t, "!", str.Parts[2].(*ast.StringLiteral).Value)
+
+	tests := []struct {
+		input    string
+		expected []string // the String() of each part
+	}{
+		{`"${a}${b}"`, []string{"a", "b"}},
+		{`"${fn() { 1 }()} and ${ {1: 2}[1] }"`, []string{"fn() 1()", " and ", "({1:2}[1])"}},
+		{`"a\${b}${c}"`, []string{"a${b}", "c"}},
+		// strings in the expression, with their own quotes and braces
+		{`"${h["k"]}"`, []string{`(h[k])`}},
+		{`"a ${f("}", "${x}")} b"`, []string{"a ", "f(}, ${x})", " b"}},
+	}
+	for _, tt := range tests {
+		p := New(lexer.New(tt.input))
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		str := program.Statements[0].(*ast.ExpressionStatement).Expression.(*ast.InterpolatedString)
+		parts := []string{}
+		for _, part := range str.Parts {
+			parts = append(parts, part.String())
+		}
+		assert.Equal(t, tt.expected, parts, tt.input)
+	}
+
+	// with only escaped ${, it's a plain string
+	p = New(lexer.New(`"cost: \${price}"`))
+	program = p.ParseProgram()
+	checkParserErrors(t, p)
+	literal := program.Statements[0].(*ast.ExpressionStatement).Expression.(*ast.StringLiteral)
+	assert.Equal(t, "cost: ${price}", literal.Value)
+}
+
+func TestInterpolatedStringErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`"a ${b"`, "unterminated ${ in string"},
+		{`"${"a}"`, "unterminated ${ in string"},
+		{`"a ${}"`, "empty ${} in string"},
+		{`"a ${let b = 1}"`, "expected one expression in ${let b = 1}"},
+		{`"a ${1; 2}"`, "expected one expression in ${1; 2}"},
+		{`"a ${1 +}"`, "no prefix parse function found for EOF"},
+	}
+	for _, tt := range tests {
+		p := New(lexer.New(tt.input))
+		p.ParseProgram()
+		assert.Equal(t, []string{tt.expected}, p.Errors(), tt.input)
+	}
+}
+
 func TestIfExpression(t *testing.T) {
 	input := "if (x < y) { x }"
 
@@ -400,6 +584,23 @@ func TestFunctionLiteralParsing(t *testing.T) {
 
 	// test the expression
 	testInfixExpression(t, bodyStmt.Expression, "x", "+", "y")
+	assert.Nil(t, fn.Name)
+}
+
+func TestNamedFunctionLiteralParsing(t *testing.T) {
+	l := lexer.New(`fn fact(n) { n }`)
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	assert.Len(t, program.Statements, 1)
+	stmt := program.Statements[0].(*ast.ExpressionStatement)
+	fn, ok := stmt.Expression.(*ast.FunctionLiteral)
+	assert.True(t, ok)
+	testIdentifier(t, fn.Name, "fact")
+	assert.Len(t, fn.Params, 1)
+	testIdentifier(t, fn.Params[0], "n")
+	assert.Equal(t, "fn fact(n) n", fn.String())
 }
 
 func TestCallExpressionParsing(t *testing.T) {
@@ -428,6 +629,56 @@ func TestCallExpressionParsing(t *testing.T) {
 	testInfixExpression(t, exp.Arguments[2], 4, "+", 5)
 }
 
+func TestMethodCallParsing(t *testing.T) {
+	input := `[1, 2].push(3 * 4)`
+	l := lexer.New(input)
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	assert.Len(t, program.Statements, 1)
+	stmt, ok := program.Statements[0].(*ast.ExpressionStatement)
+	assert.True(t, ok)
+
+	// it's a call to `push`, with the receiver as the first argument
+	exp, ok := stmt.Expression.(*ast.CallExpression)
+	if !assert.True(t, ok) {
+		return
+	}
+	assert.True(t, exp.Method)
+	testIdentifier(t, exp.Function, "push")
+	assert.Len(t, exp.Arguments, 2)
+	assert.IsType(t, &ast.ArrayLiteral{}, exp.Arguments[0])
+	testInfixExpression(t, exp.Arguments[1], 3, "*", 4)
+	assert.Equal(t, "[1, 2].push((3 * 4))", program.String())
+
+	// calls chain, and bind tighter than operators
+	p = New(lexer.New(`-a.first().len() + 1`))
+	program = p.ParseProgram()
+	checkParserErrors(t, p)
+	assert.Equal(t, "((-a.first().len()) + 1)", program.String())
+
+	// no fields, only methods
+	p = New(lexer.New(`a.b`))
+	p.ParseProgram()
+	assert.Contains(t, p.Errors(), "expected a method call after `.`, got .b")
+
+	// a receiver that failed to parse is reported, without crashing
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`99999999999999999999.foo`, "integer 99999999999999999999 is out of range: integers are 64 bits"},
+		{`(1 +).foo`, "no prefix parse function found for )"},
+		{`(1 +).foo()`, "no prefix parse function found for )"},
+	}
+	for _, tt := range tests {
+		p := New(lexer.New(tt.input))
+		p.ParseProgram()
+		assert.Equal(t, tt.expected, p.Errors()[0], tt.input)
+	}
+}
+
 func TestMapFunctionParsing(t *testing.T) {
 	input := `map(fn(x) { x * 2}, [1,2,3])`
 	l := lexer.New(input)
@@ -539,6 +790,102 @@ func TestParsingHashLiteralsStringKeys(t *testing.T) {
 	}
 }
 
+func TestParsingHashLiteralsOtherKeys(t *testing.T) {
+	input := `{1: "a", true: "b", 1 + 1: "c"}`
+	l := lexer.New(input)
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	stmt, ok := program.Statements[0].(*ast.ExpressionStatement)
+	assert.True(t, ok)
+	hash, ok := stmt.Expression.(*ast.HashLiteral)
+	assert.True(t, ok)
+	assert.Len(t, hash.Pairs, 3)
+
+	// keys are any expression
+	for key, value := range hash.Pairs {
+		switch value.String() {
+		case "a":
+			testIntegerLiteral(t, key, 1)
+		case "b":
+			testBooleanLiteral(t, key, true)
+		case "c":
+			testInfixExpression(t, key, 1, "+", 1)
+		default:
+			t.Errorf("unexpected value %s", value.String())
+		}
+	}
+}
+
+func TestNegativeNumbersInLists(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`[-1, -2, -3]`, `[(-1), (-2), (-3)]`},
+		{`[1 -2]`, `[(1 - 2)]`}, // no comma: it's a subtraction
+		{`[1, -2 - -3]`, `[1, ((-2) - (-3))]`},
+		{`[-1][0]`, `([(-1)][0])`},
+		{`-a[0]`, `(-(a[0]))`},
+		{`f(-1, -x)`, `f((-1), (-x))`},
+		{`{"a": -1}`, `{a:(-1)}`},
+	}
+	for _, tt := range tests {
+		p := New(lexer.New(tt.input))
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+		assert.Equal(t, tt.expected, program.String())
+	}
+}
+
+func TestIllegalCharacterError(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`@`, "unexpected character '@'"},
+		{`let x = 1 + $;`, "unexpected character '$'"},
+		{`let € = 1;`, "expected next token to be IDENT, got ILLEGAL instead"},
+		{`5 €`, "unexpected character '€'"},
+		{`let s = "hello`, "unterminated string literal"},
+		{`puts("a", "b)`, "unterminated string literal"},
+	}
+	for _, tt := range tests {
+		p := New(lexer.New(tt.input))
+		p.ParseProgram()
+		assert.Contains(t, p.Errors(), tt.expected, tt.input)
+	}
+}
+
+func TestTrailingCommas(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`[1, 2, 3,]`, `[1, 2, 3]`},
+		{`[1,]`, `[1]`},
+		{"[\n  1,\n  2,\n]", `[1, 2]`},
+		{`add(1, 2 * 3,)`, `add(1, (2 * 3))`},
+		{`f(x,)(y,)`, `f(x)(y)`},
+		{`[1, 2,].push(3,)`, `[1, 2].push(3)`},
+		{`{"a": 1,}`, `{a:1}`},
+	}
+	for _, tt := range tests {
+		p := New(lexer.New(tt.input))
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+		assert.Equal(t, tt.expected, program.String())
+	}
+
+	// but only one, and only at the end
+	for _, input := range []string{`[1,,]`, `[,]`, `f(,)`} {
+		p := New(lexer.New(input))
+		p.ParseProgram()
+		assert.NotEmpty(t, p.Errors(), input)
+	}
+}
+
 func TestParsingEmptyHashLiteral(t *testing.T) {
 	input := "{}"
 	l := lexer.New(input)
@@ -603,6 +950,38 @@ func TestFunctionParameterParsing(t *testing.T) {
 	}
 }
 
+func TestFunctionDefaultParameterParsing(t *testing.T) {
+	input := `fn(x, y = 10, z = x * 2) { x + y + z }`
+	l := lexer.New(input)
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	stmt := program.Statements[0].(*ast.ExpressionStatement)
+	function, ok := stmt.Expression.(*ast.FunctionLiteral)
+	assert.True(t, ok)
+
+	assert.Len(t, function.Params, 3)
+	testLiteralExpression(t, function.Params[0], "x")
+	testLiteralExpression(t, function.Params[1], "y")
+	testLiteralExpression(t, function.Params[2], "z")
+
+	assert.Len(t, function.Defaults, 3)
+	assert.Nil(t, function.Defaults[0])
+	testLiteralExpression(t, function.Defaults[1], 10)
+	testInfixExpression(t, function.Defaults[2], "x", "*", 2)
+
+	assert.Equal(t, "fn(x, y = 10, z = (x * 2)) ((x + y) + z)", function.String())
+}
+
+func TestFunctionDefaultParameterOrder(t *testing.T) {
+	l := lexer.New(`fn(x = 1, y) { x + y }`)
+	p := New(l)
+	p.ParseProgram()
+
+	assert.Equal(t, []string{"parameter y must have a default value"}, p.Errors())
+}
+
 func TestParsingPrefixExpressions(t *testing.T) {
 	prefixTests := []struct {
 		input    string
@@ -611,6 +990,7 @@ func TestParsingPrefixExpressions(t *testing.T) {
 	}{
 		{"!5;", "!", 5},
 		{"-15;", "-", 15},
+		{"~15;", "~", 15},
 		{"!true;", "!", true},
 		{"!false;", "!", false},
 	}
@@ -737,8 +1117,11 @@ func TestParsingInfixExpressions(t *testing.T) {
 		{"5 - 5;", 5, "-", 5},
 		{"5 * 5;", 5, "*", 5},
 		{"5 / 5;", 5, "/", 5},
+		{"5 ~/ 5;", 5, "~/", 5},
 		{"5 > 5;", 5, ">", 5},
 		{"5 < 5;", 5, "<", 5},
+		{"5 >= 5;", 5, ">=", 5},
+		{"5 <= 5;", 5, "<=", 5},
 		{"5 == 5;", 5, "==", 5},
 		{"5 != 5;", 5, "!=", 5},
 		{"true == true", true, "==", true},
@@ -788,6 +1171,18 @@ func TestOperatorPrecedenceParsing(t *testing.T) {
 			"3 > 5 == false",
 			"((3 > 5) == false)",
 		},
+		{
+			"a || b && c",
+			"(a || (b && c))",
+		},
+		{
+			"a && b || c && d",
+			"((a && b) || (c && d))",
+		},
+		{
+			"x == 1 || !y && z < 2",
+			"((x == 1) || ((!y) && (z < 2)))",
+		},
 		{
 			"-a * b",
 			"((-a) * b)",
@@ -816,6 +1211,10 @@ func TestOperatorPrecedenceParsing(t *testing.T) {
 			"a + b / c",
 			"(a + (b / c))",
 		},
+		{
+			"a + b ~/ c * d",
+			"(a + ((b ~/ c) * d))",
+		},
 		{
 			"a + b * c + d / e - f",
 			"(((a + (b * c)) + (d / e)) - f)",
@@ -832,6 +1231,10 @@ func TestOperatorPrecedenceParsing(t *testing.T) {
 			"5 < 4 != 3 > 4",
 			"((5 < 4) != (3 > 4))",
 		},
+		{
+			"a + 1 <= b * 2 == true",
+			"(((a + 1) <= (b * 2)) == true)",
+		},
 		{
 			"3 + 4 * 5 == 3 * 1 + 4 * 5",
 			"((3 + (4 * 5)) == ((3 * 1) + (4 * 5)))",
@@ -918,6 +1321,93 @@ func TestWhileLoop(t *testing.T) {
 	}
 }
 
+func TestWhileLoopWithElse(t *testing.T) {
+	input := `while (i < 10) { x } else { y }`
+
+	l := lexer.New(input)
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	assert.Len(t, program.Statements, 1)
+	stmt, ok := program.Statements[0].(*ast.ExpressionStatement)
+	assert.True(t, ok)
+	exp, ok := stmt.Expression.(*ast.WhileExpression)
+	assert.True(t, ok)
+
+	assert.Len(t, exp.Body.Statements, 1)
+	if !assert.NotNil(t, exp.Else) {
+		return
+	}
+	assert.Len(t, exp.Else.Statements, 1)
+	body, ok := exp.Else.Statements[0].(*ast.ExpressionStatement)
+	assert.True(t, ok)
+	testIdentifier(t, body.Expression, "y")
+
+	// no else, no Else
+	p = New(lexer.New(`while (i < 10) { x }`))
+	program = p.ParseProgram()
+	checkParserErrors(t, p)
+	assert.Nil(t, program.Statements[0].(*ast.ExpressionStatement).Expression.(*ast.WhileExpression).Else)
+}
+
+func TestDoWhileLoop(t *testing.T) {
+	input := `do { x } while (i < 10)`
+
+	l := lexer.New(input)
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	assert.Len(t, program.Statements, 1)
+	stmt, ok := program.Statements[0].(*ast.ExpressionStatement)
+	assert.True(t, ok)
+	exp, ok := stmt.Expression.(*ast.DoWhileExpression)
+	if !assert.True(t, ok) {
+		return
+	}
+
+	assert.Len(t, exp.Body.Statements, 1)
+	body, ok := exp.Body.Statements[0].(*ast.ExpressionStatement)
+	assert.True(t, ok)
+	testIdentifier(t, body.Expression, "x")
+	testInfixExpression(t, exp.Condition, "i", "<", 10)
+
+	for _, input := range []string{`do { x }`, `do x while (y)`, `do { x } while y`} {
+		p := New(lexer.New(input))
+		p.ParseProgram()
+		assert.NotEmpty(t, p.Errors(), input)
+	}
+}
+
+func TestTryExpression(t *testing.T) {
+	input := `try { x } catch (e) { e }`
+
+	l := lexer.New(input)
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	assert.Len(t, program.Statements, 1)
+	stmt, ok := program.Statements[0].(*ast.ExpressionStatement)
+	assert.True(t, ok)
+	exp, ok := stmt.Expression.(*ast.TryExpression)
+	if !assert.True(t, ok) {
+		return
+	}
+	assert.Len(t, exp.Body.Statements, 1)
+	testIdentifier(t, exp.Body.Statements[0].(*ast.ExpressionStatement).Expression, "x")
+	testIdentifier(t, exp.Ident, "e")
+	assert.Len(t, exp.Catch.Statements, 1)
+	assert.Equal(t, "try { x } catch (e) { e }", program.String())
+
+	for _, input := range []string{`try { x }`, `try { x } catch { y }`, `try { x } catch (1) { y }`, `try x catch (e) { y }`} {
+		p := New(lexer.New(input))
+		p.ParseProgram()
+		assert.NotEmpty(t, p.Errors(), input)
+	}
+}
+
 func TestForLoopWithArrayLiteral(t *testing.T) {
 	input := `for i in [1,2,3] { i }`
 
@@ -989,6 +1479,48 @@ func TestForLoopWithIdentifier(t *testing.T) {
 	}
 }
 
+func TestForLoopWithCallExpression(t *testing.T) {
+	input := `for c in chars("abc") { c }`
+
+	l := lexer.New(input)
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	assert.Len(t, program.Statements, 1)
+	stmt, ok := program.Statements[0].(*ast.ExpressionStatement)
+	assert.True(t, ok)
+	exp, ok := stmt.Expression.(*ast.ForLoop)
+	assert.True(t, ok)
+
+	testIdentifier(t, exp.Iterator, "c")
+	call, ok := exp.Ident.(*ast.CallExpression)
+	assert.True(t, ok)
+	testIdentifier(t, call.Function, "chars")
+	assert.Len(t, exp.Body.Statements, 1)
+}
+
+func TestForLoopWithArrayExpression(t *testing.T) {
+	tests := []struct {
+		input    string
+		iterable string // the String() of Ident
+	}{
+		{`for x in [1, 2] + [3] { x }`, "([1, 2] + [3])"},
+		{`for x in [[1], [2]][0] { x }`, "([[1], [2]][0])"},
+		{`for x in [1].push(2) { x }`, "[1].push(2)"},
+	}
+	for _, tt := range tests {
+		p := New(lexer.New(tt.input))
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		exp, ok := program.Statements[0].(*ast.ExpressionStatement).Expression.(*ast.ForLoop)
+		assert.True(t, ok)
+		assert.Nil(t, exp.Elements, tt.input)
+		assert.Equal(t, tt.iterable, exp.Ident.String(), tt.input)
+	}
+}
+
 func TestReassignmentExpressionParsing(t *testing.T) {
 	input := `let x = 1; x = 5 + 6`
 	l := lexer.New(input)
@@ -1013,3 +1545,120 @@ func TestReassignmentExpressionParsing(t *testing.T) {
 	// right is an infix expression
 	testInfixExpression(t, exp.Right, 5, "+", 6)
 }
+
+func TestChainedReassignmentParsing(t *testing.T) {
+	l := lexer.New(`a = b = 1`)
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	assert.Len(t, program.Statements, 1)
+	stmt := program.Statements[0].(*ast.ExpressionStatement)
+	outer, ok := stmt.Expression.(*ast.ReassignmentExpression)
+	assert.True(t, ok)
+	testIdentifier(t, outer.Left, "a")
+
+	// right-associative: a = (b = 1)
+	inner, ok := outer.Right.(*ast.ReassignmentExpression)
+	assert.True(t, ok)
+	testIdentifier(t, inner.Left, "b")
+	testIntegerLiteral(t, inner.Right, 1)
+}
+
+func TestNestedReassignmentParsing(t *testing.T) {
+	l := lexer.New(`let r = (x = 5)`)
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	assert.Len(t, program.Statements, 1)
+	stmt := program.Statements[0].(*ast.LetStatement)
+	assert.Equal(t, "r", stmt.Name.Value)
+	exp, ok := stmt.Value.(*ast.ReassignmentExpression)
+	assert.True(t, ok)
+	testIdentifier(t, exp.Left, "x")
+	testIntegerLiteral(t, exp.Right, 5)
+}
+
+func TestReassignmentParsingErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`1 = 2`, "cannot assign to 1, only to a name"},
+		{`true = 2`, "cannot assign to true, only to a name"},
+		{`f(x) = 2`, "cannot assign to an expression, only to a name"},
+		{`a + b = 2`, "cannot assign to an expression, only to a name"},
+		// unfinished, after an error: reported without crashing
+		{`(1 +) = 3`, "no prefix parse function found for )"},
+		{`f(1 +) = 2`, "no prefix parse function found for )"},
+	}
+	for _, tt := range tests {
+		p := New(lexer.New(tt.input))
+		p.ParseProgram()
+		assert.Contains(t, p.Errors(), tt.expected, tt.input)
+	}
+}
+
+func TestErrorRecovery(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected []string
+	}{
+		// each mistake is reported once, and parsing goes on after it
+		{"let = 5; let y = 10; let 3 = 4; y", []string{
+			"expected next token to be IDENT, got = instead",
+			"expected next token to be IDENT, got INT instead",
+		}},
+		// without semicolons, the next `let` (or `return`) starts over
+		{"let x 5 let y = 1 return )", []string{
+			"expected next token to be =, got INT instead",
+			"no prefix parse function found for )",
+		}},
+		// inside a block, up to the closing brace
+		{"fn() { let = 1 }; let z = 2; let = 3", []string{
+			"expected next token to be IDENT, got = instead",
+			"expected next token to be IDENT, got = instead",
+		}},
+	}
+	for _, tt := range tests {
+		p := New(lexer.New(tt.input))
+		p.ParseProgram()
+		assert.Equal(t, tt.expected, p.Errors(), tt.input)
+	}
+
+	// the statements after an error are still there
+	p := New(lexer.New("let = 5; let y = 10; y"))
+	program := p.ParseProgram()
+	n := len(program.Statements)
+	assert.Equal(t, "let y = 10;", program.Statements[n-2].String())
+	assert.Equal(t, "y", program.Statements[n-1].String())
+}
+
+func TestStatementsWithoutSemicolons(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected []string
+	}{
+		{"let a = 1 let b = 2 a + b", []string{"let a = 1;", "let b = 2;", "(a + b)"}},
+		{"let a = 1; let b = 2; a + b", []string{"let a = 1;", "let b = 2;", "(a + b)"}},
+		{"return 1 2", []string{"return 1;", "2"}},
+		{"return 1; 2", []string{"return 1;", "2"}},
+		{"let f = fn(x) { x } f(1)", []string{"let f = fn(x) x;", "f(1)"}},
+		{"let x = 1 x = 2 x", []string{"let x = 1;", "x = 2", "x"}},
+		{"if (true) { 1 } 2", []string{"iftrue 1", "2"}},
+		{"let a = [1] a[0]", []string{"let a = [1];", "(a[0])"}},
+		{"fn() { let a = 1 return a }", []string{"fn() let a = 1;return a;"}},
+	}
+	for _, tt := range tests {
+		p := New(lexer.New(tt.input))
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		statements := []string{}
+		for _, s := range program.Statements {
+			statements = append(statements, s.String())
+		}
+		assert.Equal(t, tt.expected, statements, tt.input)
+	}
+}
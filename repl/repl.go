@@ -8,23 +8,48 @@ import (
 	"monkey/lexer"
 	"monkey/object"
 	"monkey/parser"
+	"monkey/token"
+	"strings"
 )
 
 const PROMPT = "=> "
 
-func Start(in io.Reader, out io.Writer) {
+// Start reads and evaluates lines until the input ends, or until exit(code);
+// it returns the code, 0 for the end of the input
+func Start(in io.Reader, out io.Writer) int {
+	// `puts` writes to out too, for the session; and `input` reads the
+	// lines after its own from in, with the very same reader: another one
+	// could buffer them before the REPL gets to see them
+	lines := bufio.NewReader(in)
+	stdin, stdout := evaluator.Stdin, evaluator.Stdout
+	evaluator.Stdin, evaluator.Stdout = lines, out
+	defer func() { evaluator.Stdin, evaluator.Stdout = stdin, stdout }()
 
-	scanner := bufio.NewScanner(in)
+	// a single env for the whole session, so bindings survive across lines
 	env := object.NewEnvironment()
 
 	for {
-		fmt.Printf(PROMPT)
-		scanned := scanner.Scan()
-		if !scanned {
-			return
+		fmt.Fprint(out, PROMPT)
+		line, err := lines.ReadString('\n')
+		if err != nil && line == "" { // the end of the input
+			return 0
+		}
+		line = strings.TrimSuffix(strings.TrimSuffix(line, "\n"), "\r")
+
+		// REPL commands, starting with `:`
+		switch {
+		case strings.HasPrefix(line, ":tokens "):
+			printTokens(out, strings.TrimPrefix(line, ":tokens "))
+			continue
+		case strings.HasPrefix(line, ":ast "):
+			printAST(out, strings.TrimPrefix(line, ":ast "))
+			continue
+		case strings.TrimSpace(line) == ":reset": // forget every binding
+			env = object.NewEnvironment()
+			fmt.Fprintln(out, "environment reset")
+			continue
 		}
 
-		line := scanner.Text()
 		l := lexer.New(line)
 		p := parser.New(l)
 
@@ -35,14 +60,36 @@ func Start(in io.Reader, out io.Writer) {
 		}
 
 		evaluated := evaluator.Eval(program, env)
+		if exit, ok := evaluated.(*object.Exit); ok {
+			return exit.Code
+		}
 		if evaluated != nil {
-			fmt.Println(evaluated.Inspect())
+			fmt.Fprintln(out, evaluated.Inspect())
 		} else {
-			fmt.Println("nil :(")
+			fmt.Fprintln(out, "nil :(")
 		}
 	}
 }
 
+// prints the tokens of `code`, one per line, without parsing it
+func printTokens(out io.Writer, code string) {
+	l := lexer.New(code)
+	for tok := l.NextToken(); tok.Type != token.EOF; tok = l.NextToken() {
+		fmt.Fprintf(out, "%+v\n", tok)
+	}
+}
+
+// prints the AST of `code` without evaluating it; or the parse errors
+func printAST(out io.Writer, code string) {
+	p := parser.New(lexer.New(code))
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		printParserErrors(out, p.Errors())
+		return
+	}
+	fmt.Fprintln(out, program.String())
+}
+
 func printParserErrors(out io.Writer, errors []string) {
 	for _, msg := range errors {
 		io.WriteString(out, "\t"+msg+"\n")
@@ -0,0 +1,115 @@
+package repl
+
+import (
+	"bytes"
+	"github.com/stretchr/testify/assert"
+	"monkey/evaluator"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestStartKeepsEnvironment(t *testing.T) {
+	in := strings.NewReader("let x = 5\nx\nx * 2\n")
+	var out bytes.Buffer
+	Start(in, &out)
+
+	expected := PROMPT + "null\n" +
+		PROMPT + "5\n" +
+		PROMPT + "10\n" +
+		PROMPT
+	assert.Equal(t, expected, out.String())
+}
+
+func TestStartEchoesInspect(t *testing.T) {
+	in := strings.NewReader(`"hi"` + "\n" + `["hi"]` + "\n")
+	var out bytes.Buffer
+	Start(in, &out)
+
+	expected := PROMPT + `"hi"` + "\n" +
+		PROMPT + `["hi"]` + "\n" +
+		PROMPT
+	assert.Equal(t, expected, out.String())
+}
+
+func TestStartPutsToOut(t *testing.T) {
+	in := strings.NewReader(`puts("hello")` + "\n")
+	var out bytes.Buffer
+	Start(in, &out)
+
+	expected := PROMPT + "hello\n" +
+		"null\n" + // what puts returns
+		PROMPT
+	assert.Equal(t, expected, out.String())
+	assert.Equal(t, os.Stdout, evaluator.Stdout) // restored
+}
+
+func TestStartInputReadsNextLine(t *testing.T) {
+	in := strings.NewReader("let name = input()\nmonkey\nname\n")
+	var out bytes.Buffer
+	Start(in, &out)
+
+	// the second line is read by input(), not evaluated
+	expected := PROMPT + "null\n" +
+		PROMPT + `"monkey"` + "\n" +
+		PROMPT
+	assert.Equal(t, expected, out.String())
+}
+
+func TestTokensCommand(t *testing.T) {
+	in := strings.NewReader(":tokens let x = 1 + @\nx\n")
+	var out bytes.Buffer
+	Start(in, &out)
+
+	// the line is not evaluated, so x is still undefined afterwards
+	expected := PROMPT +
+		"{Type:LET Literal:let Line:1}\n" +
+		"{Type:IDENT Literal:x Line:1}\n" +
+		"{Type:= Literal:= Line:1}\n" +
+		"{Type:INT Literal:1 Line:1}\n" +
+		"{Type:+ Literal:+ Line:1}\n" +
+		"{Type:ILLEGAL Literal:@ Line:1}\n" +
+		PROMPT + "ERROR: line 1: identifier not found: x\n" +
+		PROMPT
+	assert.Equal(t, expected, out.String())
+}
+
+func TestASTCommand(t *testing.T) {
+	in := strings.NewReader(":ast 1 + 2 * 3\n:ast let x = \n:ast let y = 1\ny\n")
+	var out bytes.Buffer
+	Start(in, &out)
+
+	expected := PROMPT + "(1 + (2 * 3))\n" +
+		PROMPT + "\tno prefix parse function found for EOF\n" +
+		PROMPT + "let y = 1;\n" +
+		PROMPT + "ERROR: line 1: identifier not found: y\n" + // not evaluated
+		PROMPT
+	assert.Equal(t, expected, out.String())
+}
+
+func TestResetCommand(t *testing.T) {
+	in := strings.NewReader("let x = 5\nx\n:reset\nx\nlet x = 1\nx\n")
+	var out bytes.Buffer
+	Start(in, &out)
+
+	expected := PROMPT + "null\n" +
+		PROMPT + "5\n" +
+		PROMPT + "environment reset\n" +
+		PROMPT + "ERROR: line 1: identifier not found: x\n" +
+		PROMPT + "null\n" +
+		PROMPT + "1\n" +
+		PROMPT
+	assert.Equal(t, expected, out.String())
+}
+
+func TestExit(t *testing.T) {
+	in := strings.NewReader("1\nexit(3)\n2\n")
+	var out bytes.Buffer
+
+	code := Start(in, &out)
+
+	// the lines after exit() are never read
+	assert.Equal(t, 3, code)
+	assert.Equal(t, PROMPT+"1\n"+PROMPT, out.String())
+	assert.Equal(t, 0, Start(strings.NewReader("1\n"), &out))
+}
@@ -12,6 +12,9 @@ var keywords = map[string]TokenType{
 	"return": RETURN,
 	"map":    MAP,
 	"while":  WHILE,
+	"do":     DO,
+	"try":    TRY,
+	"catch":  CATCH,
 	"for":    FOR,
 	"in":     IN,
 }
@@ -19,6 +22,7 @@ var keywords = map[string]TokenType{
 type Token struct {
 	Type    TokenType
 	Literal string
+	Line    int // where the token starts, starting from 1
 }
 
 const (
@@ -29,6 +33,7 @@ const (
 	// Variable names + literals
 	IDENT  = "IDENT"
 	INT    = "INT"
+	FLOAT  = "FLOAT"
 	STRING = "STRING"
 
 	// Operators
@@ -38,15 +43,22 @@ const (
 	BANG     = "!"
 	ASTERISK = "*"
 	SLASH    = "/"
+	INT_DIV  = "~/" // floor division; `//` is taken by comments
+	TILDE    = "~"  // bitwise not
 	LT       = "<"
 	GT       = ">"
+	LT_EQ    = "<="
+	GT_EQ    = ">="
 	EQ       = "=="
 	NOT_EQ   = "!="
+	AND      = "&&"
+	OR       = "||"
 
 	// Delimiters
 	COMMA     = ","
 	SEMICOLON = ";"
 	COLON     = ":"
+	DOT       = "."
 
 	LPAREN   = "("
 	RPAREN   = ")"
@@ -65,6 +77,9 @@ const (
 	RETURN   = "RETURN"
 	MAP      = "MAP"
 	WHILE    = "WHILE"
+	DO       = "DO"
+	TRY      = "TRY"
+	CATCH    = "CATCH"
 	FOR      = "FOR"
 	IN       = "IN"
 )
@@ -0,0 +1,135 @@
+package vm
+
+import (
+	"fmt"
+	"monkey/code"
+	"monkey/compiler"
+	"monkey/evaluator"
+	"monkey/object"
+)
+
+const StackSize = 2048
+const GlobalsSize = 65536 // the operand of OpSetGlobal is 2 bytes
+
+// the operator each opcode stands for; the operation itself is done by
+// the evaluator, so `1 / 0` or `1 + true` fail the same way in both backends
+var infixOperators = map[code.Opcode]string{
+	code.OpAdd:          "+",
+	code.OpSub:          "-",
+	code.OpMul:          "*",
+	code.OpDiv:          "/",
+	code.OpIntDiv:       "~/",
+	code.OpEqual:        "==",
+	code.OpNotEqual:     "!=",
+	code.OpGreaterThan:  ">",
+	code.OpGreaterEqual: ">=",
+	code.OpLessThan:     "<",
+	code.OpLessEqual:    "<=",
+}
+
+var prefixOperators = map[code.Opcode]string{
+	code.OpMinus: "-",
+	code.OpBang:  "!",
+}
+
+type VM struct {
+	constants    []object.Object
+	instructions code.Instructions
+
+	stack []object.Object
+	sp    int // always points to the next free slot: the top of the stack is stack[sp-1]
+
+	globals []object.Object
+
+	lastPopped object.Object // by OpPop, i.e. the value of an expression statement
+}
+
+func New(bytecode *compiler.Bytecode) *VM {
+	return &VM{
+		constants:    bytecode.Constants,
+		instructions: bytecode.Instructions,
+		stack:        make([]object.Object, StackSize),
+		globals:      make([]object.Object, GlobalsSize),
+	}
+}
+
+// LastPoppedStackElem is the value of the last expression statement, like
+// Eval returns the value of the last statement; nil if there's none
+func (vm *VM) LastPoppedStackElem() object.Object {
+	return vm.lastPopped
+}
+
+func (vm *VM) Run() error {
+	for ip := 0; ip < len(vm.instructions); ip++ {
+		op := code.Opcode(vm.instructions[ip])
+
+		switch op {
+		case code.OpConstant:
+			constIndex := code.ReadUint16(vm.instructions[ip+1:])
+			ip += 2
+			if err := vm.push(vm.constants[constIndex]); err != nil {
+				return err
+			}
+		case code.OpTrue:
+			if err := vm.push(evaluator.TRUE); err != nil {
+				return err
+			}
+		case code.OpFalse:
+			if err := vm.push(evaluator.FALSE); err != nil {
+				return err
+			}
+		case code.OpSetGlobal:
+			globalIndex := code.ReadUint16(vm.instructions[ip+1:])
+			ip += 2
+			vm.globals[globalIndex] = vm.pop()
+		case code.OpGetGlobal:
+			globalIndex := code.ReadUint16(vm.instructions[ip+1:])
+			ip += 2
+			if err := vm.push(vm.globals[globalIndex]); err != nil {
+				return err
+			}
+		case code.OpPop:
+			vm.lastPopped = vm.pop()
+		default:
+			if operator, ok := infixOperators[op]; ok {
+				right := vm.pop()
+				left := vm.pop()
+				if err := vm.pushResult(evaluator.EvalInfix(operator, left, right)); err != nil {
+					return err
+				}
+				continue
+			}
+			if operator, ok := prefixOperators[op]; ok {
+				if err := vm.pushResult(evaluator.EvalPrefix(operator, vm.pop())); err != nil {
+					return err
+				}
+				continue
+			}
+			return fmt.Errorf("unknown opcode %d", op)
+		}
+	}
+	return nil
+}
+
+// pushResult pushes the result of an operation, unless it's an error
+func (vm *VM) pushResult(result object.Object) error {
+	if errObj, ok := result.(*object.Error); ok {
+		return fmt.Errorf("%s", errObj.Message)
+	}
+	return vm.push(result)
+}
+
+func (vm *VM) push(o object.Object) error {
+	if vm.sp >= StackSize {
+		return fmt.Errorf("stack overflow")
+	}
+	vm.stack[vm.sp] = o
+	vm.sp++
+	return nil
+}
+
+func (vm *VM) pop() object.Object {
+	o := vm.stack[vm.sp-1]
+	vm.sp--
+	return o
+}
@@ -0,0 +1,162 @@
+package vm
+
+import (
+	"github.com/stretchr/testify/assert"
+	"monkey/compiler"
+	"monkey/evaluator"
+	"monkey/lexer"
+	"monkey/object"
+	"monkey/parser"
+	"testing"
+)
+
+// the same cases as the evaluator's TestEvalIntegerExpression
+func TestIntegerArithmetic(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected int64
+	}{
+		{"5", 5},
+		{"10", 10},
+		{"-5", -5},
+		{"-10", -10},
+		{"2 + 2", 4},
+		{"5 + 5 + 5 + 5 - 10", 10},
+		{"2 * 2 * 2 * 2 * 2", 32},
+		{"-50 + 100 + -50", 0},
+		{"5 * 2 + 10", 20},
+		{"5 + 2 * 10", 25},
+		{"20 + 2 * -10", 0},
+		{"50 / 2 * 2 + 10", 60},
+		{"2 * (5 + 10)", 30},
+		{"3 * 3 * 3 + 10", 37},
+		{"3 * (3 * 3) + 10", 37},
+		{"(5 + 10 * 2 + 15 / 3) * 2 + -10", 50},
+		{"1_000 + 1", 1001},
+		{"-7 ~/ 2", -4},
+	}
+	for _, tt := range tests {
+		result := runVM(t, tt.input)
+		integer, ok := result.(*object.Integer)
+		if !ok {
+			t.Fatalf("object is not Integer. got=%T (%+v)", result, result)
+		}
+		assert.Equal(t, tt.expected, integer.Value, tt.input)
+	}
+}
+
+// the same cases as the evaluator's TestEvalBooleanExpression, plus `!`
+func TestBooleanExpressions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected bool
+	}{
+		{"true", true},
+		{"false", false},
+		{"1 < 2", true},
+		{"1 > 2", false},
+		{"1 < 1", false},
+		{"1 > 1", false},
+		{"1 == 1", true},
+		{"1 != 1", false},
+		{"1 == 2", false},
+		{"1 != 2", true},
+		{"1 <= 2", true},
+		{"2 <= 2", true},
+		{"3 <= 2", false},
+		{"1 >= 2", false},
+		{"2 >= 2", true},
+		{"true == true", true},
+		{"false == false", true},
+		{"true == false", false},
+		{"true != false", true},
+		{"false != true", true},
+		{"(1 < 2) == true", true},
+		{"(1 < 2) == false", false},
+		{"(1 > 2) == true", false},
+		{"(1 > 2) == false", true},
+		{"!true", false},
+		{"!!true", true},
+		{"!5", false},
+	}
+	for _, tt := range tests {
+		result := runVM(t, tt.input)
+		// the same objects as the evaluator's
+		if tt.expected {
+			assert.Same(t, evaluator.TRUE, result, tt.input)
+		} else {
+			assert.Same(t, evaluator.FALSE, result, tt.input)
+		}
+	}
+}
+
+func TestGlobalLetStatements(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected int64
+	}{
+		{"let one = 1; one", 1},
+		{"let one = 1; let two = 2; one + two", 3},
+		{"let one = 1; let two = one + one; one + two", 3},
+		{"let x = 1; let x = x + 1; x", 2},
+	}
+	for _, tt := range tests {
+		result := runVM(t, tt.input)
+		assert.Equal(t, tt.expected, result.(*object.Integer).Value, tt.input)
+	}
+}
+
+func TestRuntimeErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"1 / 0", "division by zero"},
+		{"1 + true", "type mismatch: INTEGER + BOOLEAN"},
+		{"-true", "unknown operator: -BOOLEAN"},
+		{"9223372036854775807 + 1", "integer overflow"},
+	}
+	for _, tt := range tests {
+		vm := New(compile(t, tt.input))
+		assert.EqualError(t, vm.Run(), tt.expected, tt.input)
+	}
+}
+
+func TestSameErrorsAsEvaluator(t *testing.T) {
+	for _, input := range []string{
+		"true < false",
+		"true <= false",
+		"1 < true",
+		"false >= 1",
+		"(1 < 2) < 3",
+		"1 - false",
+	} {
+		expected, ok := evaluator.Eval(parser.New(lexer.New(input)).ParseProgram(), object.NewEnvironment()).(*object.Error)
+		if !ok {
+			t.Fatalf("no error from the evaluator for %s", input)
+		}
+		vm := New(compile(t, input))
+		assert.EqualError(t, vm.Run(), expected.Message, input)
+	}
+}
+
+// helpers
+
+func runVM(t *testing.T, input string) object.Object {
+	t.Helper()
+	vm := New(compile(t, input))
+	if err := vm.Run(); err != nil {
+		t.Fatalf("vm error: %s", err)
+	}
+	return vm.LastPoppedStackElem()
+}
+
+func compile(t *testing.T, input string) *compiler.Bytecode {
+	t.Helper()
+	program := parser.New(lexer.New(input)).ParseProgram()
+	comp := compiler.New()
+	if err := comp.Compile(program); err != nil {
+		t.Fatalf("compiler error: %s", err)
+	}
+	return comp.Bytecode()
+}